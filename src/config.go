@@ -0,0 +1,285 @@
+package main
+
+import "crypto/tls"
+import "errors"
+import "flag"
+import "fmt"
+import "net"
+import "net/url"
+import "os"
+import "secureWorks"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+
+const defaultApiUri = "https://ws.secureworks.com/api/TicketingService"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+	fmt.Fprintf(os.Stderr, "Usage: config init -o <file>   Create a config file interactively\n")
+	fmt.Fprintf(os.Stderr, "       config test -c <file>   Check connectivity, TLS and credentials\n")
+	os.Exit(0)
+}
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "init":
+		configInit(os.Args[2:])
+	case "test":
+		configTest(os.Args[2:])
+	default:
+		usage()
+	}
+}
+func prompt(label string, def string) string {
+	if len(def) > 0 {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", label)
+	}
+	/* Unbuffered, so ReadSecret sees the rest of stdin */
+	var b [1]byte
+	line := ""
+	for n, _ := os.Stdin.Read(b[:]); n > 0 && b[0] != '\n'; n, _ = os.Stdin.Read(b[:]) {
+		line += string(b[0])
+	}
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return def
+	}
+	return line
+}
+
+/* choose lists options as a numbered menu and returns the picked Id */
+func choose(label string, options []secureWorks.IdName) string {
+	if len(options) == 0 {
+		return prompt(label+" (none found, enter manually or leave empty)", "")
+	}
+	for i, v := range options {
+		fmt.Fprintf(os.Stderr, "  %2d) %s (%d)\n", i+1, v.Name, v.Id)
+	}
+	for {
+		a := prompt(label+" [1-"+strconv.Itoa(len(options))+"]", "1")
+		n, err := strconv.Atoi(a)
+		if err == nil && n >= 1 && n <= len(options) {
+			return strconv.Itoa(options[n-1].Id)
+		}
+		fmt.Fprintf(os.Stderr, "Enter a number from the list\n")
+	}
+}
+
+/* Password sources offered by config init; plaintext only when picked explicitly */
+var passwordSources = []string{
+	"PasswordEnv: environment variable",
+	"PasswordFile: file readable only by you",
+	"PasswordCommand: command printing it (e.g. a vault CLI)",
+	"EncryptedPassword: encrypted with a passphrase",
+	"Password: plaintext in the config file",
+}
+
+/*
+ * passwordSource asks where the password comes from and returns q with
+ * that source set, plus a copy with the password resolved.
+ */
+func passwordSource(q secureWorks.Query, out string) (secureWorks.Query, secureWorks.Query, error) {
+	fmt.Fprintf(os.Stderr, "Password source:\n")
+	for i, v := range passwordSources {
+		fmt.Fprintf(os.Stderr, "  %2d) %s\n", i+1, v)
+	}
+	a := prompt("Source [1-"+strconv.Itoa(len(passwordSources))+"]", "1")
+	for n, err := strconv.Atoi(a); err != nil || n < 1 || n > len(passwordSources); n, err = strconv.Atoi(a) {
+		fmt.Fprintf(os.Stderr, "Enter a number from the list\n")
+		a = prompt("Source [1-"+strconv.Itoa(len(passwordSources))+"]", "1")
+	}
+	switch a {
+	case "1":
+		q.PasswordEnv = prompt("Environment variable", "SECUREWORKS_PASSWORD")
+	case "2":
+		q.PasswordFile = prompt("File", "")
+	case "3":
+		q.PasswordCommand = prompt("Command", "")
+	case "4":
+		p, err := secureWorks.ReadSecret("Password: ")
+		if err != nil {
+			return q, q, err
+		}
+		pass, ok := os.LookupEnv(secureWorks.PassphraseEnv)
+		if !ok {
+			if pass, err = secureWorks.ReadSecret("Passphrase: "); err != nil {
+				return q, q, err
+			}
+			/* so reading the written config back does not ask again */
+			secureWorks.PassphraseFunc = func() (string, error) { return pass, nil }
+		}
+		if q.EncryptedPassword, err = secureWorks.EncryptPassword(p, pass); err != nil {
+			return q, q, err
+		}
+		fmt.Fprintf(os.Stderr, "The passphrase is read from $%s, or prompted for, when the config is loaded\n", secureWorks.PassphraseEnv)
+		l := q
+		l.Password = p
+		return q, l, nil
+	case "5":
+		if a := prompt("Store the password in plaintext in "+out+"? [y/N]", "n"); !strings.EqualFold(a, "y") {
+			return q, q, errors.New("no password source chosen")
+		}
+		p, err := secureWorks.ReadSecret("Password: ")
+		if err != nil {
+			return q, q, err
+		}
+		q.Password = p
+		return q, q, nil
+	}
+	l := q
+	return q, l, l.ResolvePassword()
+}
+func configInit(args []string) {
+	fs := flag.NewFlagSet("config init", flag.ExitOnError)
+	Out := fs.String("o", "", "Config File to write, .xml, .json or .yaml <required>")
+	Force := fs.Bool("f", false, "Overwrite an existing Config File")
+	fs.Parse(args)
+	if len(*Out) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+	if _, err := os.Stat(*Out); err == nil && *Force == false {
+		fmt.Fprintf(os.Stderr, "%s exists, use -f to overwrite\n", *Out)
+		os.Exit(1)
+	}
+
+	q := secureWorks.Query{}
+	q.ApiUri = prompt("ApiUri", defaultApiUri)
+	q.UserName = prompt("UserName", "")
+	/* q is written out, l carries the resolved password for the lookups below */
+	q, l, err := passwordSource(q, *Out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := l.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	c, err := secureWorks.GetCustomerList(l)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	q.ClientId = choose("ClientId", c.ClientInfo)
+	l.ClientId = q.ClientId
+
+	/* Locations are only exposed through the devices that belong to them */
+	var locations []secureWorks.IdName
+	if len(q.ClientId) > 0 {
+		d, err := secureWorks.GetDeviceList(l)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: could not list locations: %v\n", err)
+		} else {
+			seen := map[int]bool{}
+			for _, v := range d.Devices {
+				if !seen[v.Location.Id] {
+					seen[v.Location.Id] = true
+					locations = append(locations, v.Location)
+				}
+			}
+			sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+		}
+	}
+	q.LocationId = choose("LocationId", locations)
+	l.LocationId = q.LocationId
+	if err := l.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := secureWorks.WriteConfig(*Out, q); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := secureWorks.ReadConfigProfile(*Out, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: written config does not validate: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *Out)
+}
+func configTest(args []string) {
+	fs := flag.NewFlagSet("config test", flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File <required>")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if len(*fileName) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	ok := true
+	check := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Printf("FAIL %s: %v\n", name, err)
+		} else {
+			fmt.Printf("OK   %s\n", name)
+		}
+	}
+
+	q, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	check("config", err)
+	if err != nil {
+		os.Exit(1)
+	}
+	if *Debug == true {
+		q.Debug = os.Stderr
+	}
+
+	u, _ := url.Parse(q.ApiUri)
+	host := u.Host
+	if len(u.Port()) == 0 {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	check("connect "+host, err)
+	if err != nil {
+		os.Exit(1)
+	}
+	conn.Close()
+
+	/* Requests skip certificate verification, so report it separately */
+	if u.Scheme == "https" {
+		d := &net.Dialer{Timeout: 10 * time.Second}
+		tc, err := tls.DialWithDialer(d, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+		if err == nil {
+			st := tc.ConnectionState()
+			fmt.Printf("     TLS %s, certificate %s expires %s\n", tls.VersionName(st.Version),
+				st.PeerCertificates[0].Subject.CommonName,
+				st.PeerCertificates[0].NotAfter.Format("2006-01-02"))
+			tc.Close()
+		}
+		check("tls certificate", err)
+	}
+
+	_, err = secureWorks.GetCustomerList(q)
+	var fault *secureWorks.FaultError
+	if errors.As(err, &fault) {
+		fmt.Printf("     FaultCode: %s\n     FaultString: %s\n     FaultInfo: %s\n     Reason: %s\n",
+			fault.Fault.FaultCode, fault.Fault.FaultString,
+			fault.Fault.Detail.FaultInfo.FaultCode, fault.Fault.Detail.FaultInfo.Reason)
+	}
+	check("credentials (getCustomerList)", err)
+
+	if len(q.ClientId) > 0 {
+		_, err = secureWorks.GetDeviceList(q)
+		check("ClientId/LocationId (getDeviceList)", err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/archive"
+import "secureWorks/snapshot"
+import "flag"
+import "strconv"
+import "strings"
+import "time"
+import "context"
+import "os/signal"
+import "encoding/json"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+	fmt.Fprintf(os.Stderr, "Usage: devices list -c config [-r]               List the cached device inventory\n")
+	fmt.Fprintf(os.Stderr, "       devices tickets [-d dir] [-c config] dev  List archived tickets for a device\n")
+	fmt.Fprintf(os.Stderr, "                                                 (by id, name, alias or IP)\n")
+	fmt.Fprintf(os.Stderr, "       devices snapshot -c config [-i interval]  Snapshot the device list, report changes\n")
+	fmt.Fprintf(os.Stderr, "       devices diff -c config [-from t] [-to t]  Changes between two snapshots\n")
+	os.Exit(0)
+}
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "list":
+		devicesList(os.Args[2:])
+	case "tickets":
+		devicesTickets(os.Args[2:])
+	case "snapshot":
+		devicesSnapshot(os.Args[2:])
+	case "diff":
+		devicesDiff(os.Args[2:])
+	default:
+		usage()
+	}
+}
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+/* inventoryFlags are shared by the subcommands that read the inventory */
+type inventoryFlags struct {
+	fileName *string
+	profile  *string
+	cache    *string
+	maxAge   *time.Duration
+	debug    *bool
+}
+
+func addInventoryFlags(fs *flag.FlagSet) inventoryFlags {
+	return inventoryFlags{
+		fileName: fs.String("c", "", "Config File"),
+		profile:  fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)"),
+		cache:    fs.String("cache", "secureworks-devices.json", "Device Cache File (when the config sets no DeviceCache)"),
+		maxAge:   fs.Duration("max-age", time.Hour, "Refresh the cache when older than this"),
+		debug:    fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr"),
+	}
+}
+
+/*
+ * devices returns the inventory: through the API (refreshed if stale, or
+ * always with refresh) when a config is given, else the cache file as is.
+ */
+func (f inventoryFlags) devices(refresh bool) ([]secureWorks.DeviceList, error) {
+	if len(*f.fileName) == 0 {
+		return secureWorks.NewInventory(*f.cache, *f.maxAge).Cached(), nil
+	}
+	l, err := secureWorks.ReadConfigProfile(*f.fileName, *f.profile)
+	if err != nil {
+		return nil, err
+	}
+	if *f.debug == true {
+		l.Debug = os.Stderr
+	}
+	if l.Inventory == nil {
+		l.Inventory = secureWorks.NewInventory(*f.cache, *f.maxAge)
+	}
+	if refresh == true {
+		if err := l.Inventory.Refresh(l); err != nil {
+			return nil, err
+		}
+	}
+	return l.Inventory.Devices(l)
+}
+
+func devicesList(args []string) {
+	fs := flag.NewFlagSet("devices list", flag.ExitOnError)
+	f := addInventoryFlags(fs)
+	Refresh := fs.Bool("r", false, "Refresh the cache now")
+	fs.Parse(args)
+
+	l, err := f.devices(*Refresh)
+	if err != nil {
+		if l == nil {
+			fail(err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: refresh failed, using cached list: %v\n", err)
+	}
+	fmt.Printf("DeviceId,DeviceName,DeviceAlias,DeviceIp,Location\n")
+	for _, d := range l {
+		fmt.Printf("%d,%s,%s,%s,%s\n", d.DeviceId, d.DeviceName, d.DeviceAlias, d.DeviceIp, d.Location.Name)
+	}
+}
+
+/* matches reports whether s names d by id, name, alias or IP */
+func matches(d secureWorks.DeviceList, s string) bool {
+	return strconv.Itoa(d.DeviceId) == s || strings.EqualFold(d.DeviceName, s) ||
+		strings.EqualFold(d.DeviceAlias, s) || d.DeviceIp == s
+}
+
+func devicesTickets(args []string) {
+	fs := flag.NewFlagSet("devices tickets", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	f := addInventoryFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+	dev := fs.Arg(0)
+
+	/* Resolve dev to inventory records so an alias or IP finds tickets archived before enrichment */
+	l, err := f.devices(false)
+	if err != nil && l == nil {
+		fail(err)
+	}
+	var found []secureWorks.DeviceList
+	for _, d := range l {
+		if matches(d, dev) {
+			found = append(found, d)
+		}
+	}
+
+	a, err := archive.Open(*Dir)
+	if err != nil {
+		fail(err)
+	}
+	tickets, err := a.List()
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("TicketId,TicketVersion,DateModified,Severity,Status,Client,Device,SymptomDescription\n")
+	for _, t := range tickets {
+		hit := strconv.Itoa(t.Devices.Id) == dev || strings.EqualFold(t.Devices.Name, dev) ||
+			strings.EqualFold(t.DeviceAlias, dev) || (len(t.DeviceIp) > 0 && t.DeviceIp == dev)
+		for n := 0; !hit && n < len(found); n++ {
+			hit = secureWorks.FindDevice(found[n:n+1], t) != nil
+		}
+		if hit {
+			fmt.Printf("%s,%s,%d,%s,%s,%s,%s,%s\n", t.TicketId, t.TicketVersion, t.DateModified,
+				t.Severity, t.Status, t.Client.Name, t.Devices.Name, t.SymptomDescription)
+		}
+	}
+}
+
+/* printReport writes r as text, csv or json (one report per line) */
+func printReport(r snapshot.Report, format string, header bool) {
+	switch format {
+	case "json":
+		b, _ := json.Marshal(r)
+		fmt.Printf("%s\n", b)
+	case "csv":
+		if err := r.WriteCSV(os.Stdout, header); err != nil {
+			fail(err)
+		}
+	default:
+		from := "(none)"
+		if !r.From.IsZero() {
+			from = r.From.Format(time.RFC3339)
+		}
+		fmt.Printf("Client %s Location %s: %s -> %s, %d changes\n", r.ClientId, r.LocationId,
+			from, r.To.Format(time.RFC3339), len(r.Changes))
+		for _, c := range r.Changes {
+			switch c.Change {
+			case secureWorks.DeviceAdded:
+				fmt.Printf("  + %d %s %s\n", c.DeviceId, c.DeviceName, c.To)
+			case secureWorks.DeviceRemoved:
+				fmt.Printf("  - %d %s %s\n", c.DeviceId, c.DeviceName, c.From)
+			default:
+				fmt.Printf("  ~ %d %s %s: %q -> %q\n", c.DeviceId, c.DeviceName, c.Change, c.From, c.To)
+			}
+		}
+	}
+}
+func devicesSnapshot(args []string) {
+	fs := flag.NewFlagSet("devices snapshot", flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File <required>")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Dir := fs.String("s", "secureworks-snapshots", "Snapshot Directory")
+	Interval := fs.Duration("i", 0, "Take a snapshot every interval until interrupted (default: once)")
+	Format := fs.String("f", "text", "Report Format: text, csv or json")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if len(*fileName) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	s, err := snapshot.Open(*Dir)
+	if err != nil {
+		fail(err)
+	}
+	if *Interval <= 0 {
+		r, err := s.Take(l)
+		if err != nil {
+			fail(err)
+		}
+		printReport(r, *Format, true)
+		return
+	}
+
+	/* Periodic: only changes are reported, and failures don't stop the loop */
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	l = l.WithContext(ctx)
+	for n := 0; ; n++ {
+		r, err := s.Take(l)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		case err == nil && (n == 0 || len(r.Changes) > 0):
+			printReport(r, *Format, n == 0)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*Interval):
+		}
+	}
+}
+
+/* parseWhen takes RFC 3339, a date, or a snapshot stamp; empty means now */
+func parseWhen(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			if layout == "2006-01-02" {
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q", s)
+}
+func devicesDiff(args []string) {
+	fs := flag.NewFlagSet("devices diff", flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File (for ClientId/LocationId)")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	ClientId := fs.String("client", "", "ClientId (instead of -c)")
+	LocationId := fs.String("location", "", "LocationId (instead of -c)")
+	Dir := fs.String("s", "secureworks-snapshots", "Snapshot Directory")
+	From := fs.String("from", "", "Compare the snapshot at or before this time (default: the one before -to)")
+	To := fs.String("to", "", "With the snapshot at or before this time (default: latest)")
+	List := fs.Bool("l", false, "List the snapshot times instead")
+	Format := fs.String("f", "text", "Report Format: text, csv or json")
+	fs.Parse(args)
+	if len(*fileName) == 0 && len(*ClientId) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+	if len(*fileName) > 0 {
+		l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		*ClientId, *LocationId = l.ClientId, l.LocationId
+	}
+
+	s, err := snapshot.Open(*Dir)
+	if err != nil {
+		fail(err)
+	}
+	if *List == true {
+		times, err := s.Times(*ClientId, *LocationId)
+		if err != nil {
+			fail(err)
+		}
+		for _, t := range times {
+			fmt.Printf("%s\n", t.Format(time.RFC3339))
+		}
+		return
+	}
+	to, err := parseWhen(*To)
+	if err != nil {
+		fail(err)
+	}
+	b, err := s.At(*ClientId, *LocationId, to)
+	if err != nil {
+		fail(err)
+	}
+	var a snapshot.Snapshot
+	if len(*From) > 0 {
+		from, err := parseWhen(*From)
+		if err != nil {
+			fail(err)
+		}
+		a, err = s.At(*ClientId, *LocationId, from)
+		if err != nil {
+			fail(err)
+		}
+	} else if a, err = s.At(*ClientId, *LocationId, b.Taken.Add(-time.Second)); err != nil && err != snapshot.ErrNotFound {
+		fail(err)
+	}
+	printReport(snapshot.Diff(a, b), *Format, true)
+}
@@ -0,0 +1,122 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/archive"
+import "secureWorks/digest"
+import "flag"
+import "strings"
+import "time"
+
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	fileName := flag.String("c", "", "Config File (tickets from GetUpdates)")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Dir := flag.String("d", "", "Archive Directory (tickets from the local archive instead of GetUpdates)")
+	TicketTypes := flag.String("t", "INCIDENT", "Ticket Types, comma separated (GetUpdates)")
+	Limit := flag.Int("l", 500, "Ticket Limit per Ticket Type (GetUpdates, Max is 500)")
+	Window := flag.Duration("w", 24*time.Hour, "Include tickets created or modified within this window")
+	Smtp := flag.String("smtp", "", "SMTP Server host:port <required unless -n>")
+	From := flag.String("from", "", "From Address")
+	To := flag.String("to", "", "Recipients, comma separated")
+	User := flag.String("u", "", "SMTP Username (password from $SECUREWORKS_SMTP_PASSWORD)")
+	Tls := flag.Bool("tls", false, "Connect with TLS (port 465) instead of STARTTLS")
+	Insecure := flag.Bool("insecure", false, "Send without TLS if the server does not offer STARTTLS")
+	Subject := flag.String("s", "SecureWorks digest {{date .To}}: {{.Total}} tickets", "Subject Template")
+	TextFile := flag.String("text", "", "Plaintext Body Template File <optional>")
+	HtmlFile := flag.String("html", "", "HTML Body Template File <optional>")
+	DryRun := flag.Bool("n", false, "Print the message instead of sending it")
+	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	flag.Parse()
+	if (len(*fileName) == 0 && len(*Dir) == 0) || *Help == true || (len(*Smtp) == 0 && *DryRun == false) {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Must specify Config file with -c or Archive with -d, and -smtp or -n\n")
+		os.Exit(0)
+	}
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	subject, err := digest.ParseText("subject", *Subject)
+	if err != nil {
+		fail(err)
+	}
+	textTmpl, err := digest.ParseText("text", digest.DefaultText)
+	if len(*TextFile) > 0 && err == nil {
+		var b []byte
+		if b, err = os.ReadFile(*TextFile); err == nil {
+			textTmpl, err = digest.ParseText(*TextFile, string(b))
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+	htmlTmpl, err := digest.ParseHTML("html", digest.DefaultHTML)
+	if len(*HtmlFile) > 0 && err == nil {
+		var b []byte
+		if b, err = os.ReadFile(*HtmlFile); err == nil {
+			htmlTmpl, err = digest.ParseHTML(*HtmlFile, string(b))
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	var tickets []secureWorks.Ticket
+	if len(*Dir) > 0 {
+		a, err := archive.Open(*Dir)
+		if err == nil {
+			tickets, err = a.List()
+		}
+		if err != nil {
+			fail(err)
+		}
+	} else {
+		l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if *Debug == true {
+			l.Debug = os.Stderr
+		}
+		for _, tt := range strings.Split(*TicketTypes, ",") {
+			u, err := secureWorks.GetUpdates(l, strings.TrimSpace(tt), "ALL", *Limit, 0)
+			if err != nil {
+				fail(err)
+			}
+			tickets = append(tickets, u.Tickets...)
+		}
+	}
+
+	now := time.Now()
+	d := digest.Build(tickets, now.Add(-*Window), now)
+	text, html, err := d.Render(textTmpl, htmlTmpl)
+	if err != nil {
+		fail(err)
+	}
+	var sb strings.Builder
+	if err := subject.Execute(&sb, d); err != nil {
+		fail(err)
+	}
+
+	var rcpt []string
+	for _, r := range strings.Split(*To, ",") {
+		if r = strings.TrimSpace(r); len(r) > 0 {
+			rcpt = append(rcpt, r)
+		}
+	}
+	if *DryRun == true {
+		os.Stdout.Write(digest.Message(*From, rcpt, sb.String(), text, html))
+		return
+	}
+	m := &digest.Mailer{Addr: *Smtp, Username: *User, Password: os.Getenv("SECUREWORKS_SMTP_PASSWORD"),
+		From: *From, To: rcpt, TLS: *Tls, Insecure: *Insecure}
+	if err := m.Send(sb.String(), text, html); err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "flag"
+
+func main() {
+	Help := flag.Bool("h", false, "Help")
+	flag.Parse()
+	if *Help == true {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		fmt.Fprintf(os.Stderr, "Prints an EncryptedPassword config value; the passphrase is read from $%s or prompted for\n",
+			secureWorks.PassphraseEnv)
+		flag.PrintDefaults()
+		os.Exit(0)
+	}
+
+	p, err := secureWorks.ReadSecret("Password: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	pass, ok := os.LookupEnv(secureWorks.PassphraseEnv)
+	if !ok {
+		pass, err = secureWorks.ReadSecret("Passphrase: ")
+		if err == nil {
+			var again string
+			again, err = secureWorks.ReadSecret("Passphrase (again): ")
+			if err == nil && again != pass {
+				err = fmt.Errorf("passphrases do not match")
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	e, err := secureWorks.EncryptPassword(p, pass)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("<EncryptedPassword>%s</EncryptedPassword>\n", e)
+}
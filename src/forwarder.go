@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/forward"
+import "flag"
+import "context"
+import "os/signal"
+import "strings"
+import "time"
+
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Targets := flag.String("f", "", "Forwarder Targets File (JSON) <required>")
+	Dir := flag.String("d", "secureworks-forwarder", "State Directory (outbox and delivery status)")
+	TicketTypes := flag.String("t", "INCIDENT", "Ticket Types, comma separated")
+	Interval := flag.Duration("i", time.Minute, "Poll Interval")
+	Status := flag.Bool("s", false, "Print delivery status and exit")
+	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	flag.Parse()
+	if len(*Targets) == 0 || (len(*fileName) == 0 && *Status == false) || *Help == true {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Must specify Config file with -c and Targets file with -f\n")
+		os.Exit(0)
+	}
+
+	t, err := forward.LoadTargets(*Targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	f, err := forward.New(*Dir, t...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	f.TicketTypes = strings.Split(*TicketTypes, ",")
+
+	if *Status == true {
+		fmt.Printf("Target,TicketId,Version,State,Attempts,DeliveredAt,LastError\n")
+		for _, s := range f.Statuses() {
+			d := ""
+			if !s.DeliveredAt.IsZero() {
+				d = s.DeliveredAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s,%s,%s,%s,%d,%s,%q\n", s.Target, s.TicketId, s.Version, s.State, s.Attempts, d, s.LastError)
+		}
+		return
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	f.Run(ctx, l, *Interval, func(err error) {
+		fmt.Fprintf(os.Stderr, "%s Error: %v\n", time.Now().Format(time.RFC3339), err)
+	})
+}
@@ -8,11 +8,14 @@ import "encoding/base64"
 import "io/ioutil"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
 	Help := flag.Bool("h", false, "Help")
 	AtId := flag.String("i", "", "Attachment Id <required>")
 	Out := flag.String("o", "", "Filename <optional> (Output attachment to file)")
 	TicketNumber := flag.String("t", "", "Ticket Number <required>")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 || len(*TicketNumber) == 0 || len(*AtId) == 0 || *Help == true {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -21,12 +24,20 @@ func main() {
 		os.Exit(0)
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
 	a, err := secureWorks.GetAttachment(l, *TicketNumber, *AtId)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	if len(*Out) == 0 {
 		fmt.Printf("Content: %s\nFilename: %s\nmd5Sum: %s\n",
@@ -35,10 +46,12 @@ func main() {
 			a.Md5Sum)
 	} else {
 		d, err := base64.StdEncoding.DecodeString(a.Content)
+		if err == nil {
+			err = ioutil.WriteFile(*Out, d, 0722)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		} else {
-			ioutil.WriteFile(*Out, d, 0722)
+			os.Exit(1)
 		}
 	}
 }
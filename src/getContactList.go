@@ -6,7 +6,10 @@ import "secureWorks"
 import "flag"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -15,14 +18,19 @@ func main() {
 		os.Exit(0)
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
 	x, err := secureWorks.GetContactList(l)
 	if err != nil {
-		fmt.Printf("Error: %q\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 	fmt.Printf("Id,Name\n")
 	for _, v := range x.Contacts {
@@ -6,9 +6,12 @@ import "secureWorks"
 import "flag"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
 	TicketType := flag.String("t", "", "Ticket Type")
 	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 || *Help == true {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -21,14 +24,19 @@ func main() {
 		*TicketType = "INCIDENT"
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
 	c, err := secureWorks.GetQueueCount(l, *TicketType)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	fmt.Fprintf(os.Stdout, "%d\n", c.Count)
@@ -6,10 +6,13 @@ import "secureWorks"
 import "flag"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
 	TicketType := flag.String("t", "", "Ticket Type")
 	Limit := flag.Int("l", 25, "Ticket Limit (Max is 500) Default is 25")
 	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 || *Help == true {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -22,12 +25,20 @@ func main() {
 		*TicketType = "INCIDENT"
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
-	y, _ := secureWorks.GetQueueTicketIds(l, *TicketType, *Limit)
+	y, err := secureWorks.GetQueueTicketIds(l, *TicketType, *Limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	for _, v := range y.TicketIds {
 		fmt.Printf("%s\n", v)
 	}
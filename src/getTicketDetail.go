@@ -6,13 +6,16 @@ import "secureWorks"
 import "flag"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
 	TicketNumber := flag.String("t", "", "Ticket Number <required>")
 	Csv := flag.Bool("C", false, "CSV Output")
 	Long := flag.Bool("L", false, "Long Output")
 	Short := flag.Bool("S", false, "Short Output (don't include work logs)")
 	Work := flag.Bool("W", false, "Show Work Logs Only")
 	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 || *Help == true || len(*TicketNumber) == 0 {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -25,12 +28,20 @@ func main() {
 		*Long = true
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
-	d, _ := secureWorks.GetTicketDetail(l, *TicketNumber)
+	d, err := secureWorks.GetTicketDetail(l, *TicketNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	if *Csv == true {
 		d.Detail.PrintCsv()
 	}
@@ -1,18 +1,27 @@
 package main
 
+import "context"
 import "fmt"
 import "os"
 import "secureWorks"
+import "secureWorks/siem"
 import "flag"
 
 func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
 	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
 	TicketNumber := flag.String("t", "", "Ticket Number <required>")
 	Csv := flag.Bool("C", false, "CSV Output")
 	Long := flag.Bool("L", false, "Long Output")
 	Short := flag.Bool("S", false, "Short Output (don't include work logs)")
 	Work := flag.Bool("W", false, "Show Work Logs Only")
+	Format := flag.String("F", "", "SIEM Output Format: cef or leef (one line per ticket)")
+	WorkLogs := flag.Bool("w", false, "With -F, also emit one line per Work Log")
+	Syslog := flag.String("syslog", "", "With -F, send RFC 5424 syslog to udp://, tcp:// or tls://host:port instead of stdout")
+	Facility := flag.String("facility", "user", "Syslog Facility")
 	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
 	flag.Parse()
 	if len(*fileName) == 0 || *Help == true || len(*TicketNumber) == 0 {
 		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
@@ -20,18 +29,58 @@ func main() {
 		os.Exit(0)
 
 	}
-	if *Csv == false && *Work == false && *Short == false && *Long == false {
+	var sl *siem.Syslog
+	if len(*Format) > 0 {
+		_, err := siem.Format(*Format, secureWorks.Ticket{}, nil)
+		if err == nil && len(*Syslog) > 0 {
+			sl, err = siem.NewSyslog(*Syslog)
+			if err == nil {
+				sl.Facility, err = siem.Facility(*Facility)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *Csv == false && *Work == false && *Short == false && *Long == false {
 		fmt.Fprintf(os.Stderr, "WARN: No Output Option Selected, using default: Long\n")
 		*Long = true
 	}
 
-	l, err := secureWorks.ReadConfig(*fileName)
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
 	}
 
-	d, _ := secureWorks.GetUpdates(l, "INCIDENT", "ALL", 2)
+	d, err := secureWorks.GetUpdates(l, "INCIDENT", "ALL", 2, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	for _, v := range d.Tickets {
+		if len(*Format) > 0 {
+			var w []secureWorks.WorkLog
+			if *WorkLogs == true {
+				w = v.WorkLogs
+			}
+			if sl != nil {
+				if err := sl.SendTicket(context.Background(), *Format, v, w); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				continue
+			}
+			line, _ := siem.Format(*Format, v, nil)
+			fmt.Println(line)
+			for i := range w {
+				line, _ := siem.Format(*Format, v, &w[i])
+				fmt.Println(line)
+			}
+		}
 		if *Csv == true {
 			v.PrintCsv()
 		}
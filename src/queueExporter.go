@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "flag"
+import "net/http"
+import "strings"
+import "time"
+
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	TicketTypes := flag.String("t", "INCIDENT", "Ticket Types, comma separated")
+	Interval := flag.Duration("i", time.Minute, "Poll Interval")
+	Listen := flag.String("l", ":9464", "Listen Address for /metrics")
+	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	flag.Parse()
+	if len(*fileName) == 0 || *Help == true {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Must specify Config file with -c\n")
+		os.Exit(0)
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	m := secureWorks.NewPrometheusMetrics()
+	l.Metrics = m
+
+	/* Poll queue depth per ticket type in the background */
+	go func() {
+		for {
+			for _, t := range strings.Split(*TicketTypes, ",") {
+				c, err := secureWorks.GetQueueCount(l, strings.TrimSpace(t))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s: %v\n", t, err)
+					continue
+				}
+				m.SetQueueDepth(strings.TrimSpace(t), c.Count)
+			}
+			time.Sleep(*Interval)
+		}
+	}()
+
+	http.Handle("/metrics", m)
+	err = http.ListenAndServe(*Listen, nil)
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,246 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/archive"
+import "secureWorks/sla"
+import "flag"
+import "encoding/csv"
+import "encoding/json"
+import "strconv"
+import "strings"
+import "text/tabwriter"
+import "time"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+	fmt.Fprintf(os.Stderr, "Usage: report sla [-d dir] [-thresholds file] [-by dims] [-f text|csv|json]\n")
+	fmt.Fprintf(os.Stderr, "                  Response times of archived tickets, with SLA breaches\n")
+	os.Exit(0)
+}
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "sla":
+		reportSla(os.Args[2:])
+	default:
+		usage()
+	}
+}
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+/* parseDate takes 2006-01-02 or RFC 3339 */
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+func reportSla(args []string) {
+	fs := flag.NewFlagSet("report sla", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	ThresholdFile := fs.String("thresholds", "", "SLA Thresholds JSON File <optional> (no breaches without)")
+	By := fs.String("by", strings.Join(sla.Dimensions, ","), "Breakdowns, comma separated: severity, client, service, responsible")
+	From := fs.String("from", "", "Only tickets created on or after this date")
+	To := fs.String("to", "", "Only tickets created before this date")
+	TicketTypes := fs.String("t", "", "Only these Ticket Types, comma separated")
+	Format := fs.String("f", "text", "Output Format: text, csv or json")
+	Breaches := fs.Bool("breaches", false, "List only the breaches")
+	Tickets := fs.Bool("tickets", false, "List the per-ticket figures instead")
+	fs.Parse(args)
+
+	var th *sla.Thresholds
+	if len(*ThresholdFile) > 0 {
+		var err error
+		if th, err = sla.LoadThresholds(*ThresholdFile); err != nil {
+			fail(err)
+		}
+	}
+	var dims []string
+	for _, d := range strings.Split(*By, ",") {
+		if d = strings.TrimSpace(d); len(d) == 0 {
+			continue
+		}
+		if !contains(sla.Dimensions, d) {
+			fail(fmt.Errorf("unknown breakdown %q", d))
+		}
+		dims = append(dims, d)
+	}
+	var from, to time.Time
+	var err error
+	if len(*From) > 0 {
+		if from, err = parseDate(*From); err != nil {
+			fail(err)
+		}
+	}
+	if len(*To) > 0 {
+		if to, err = parseDate(*To); err != nil {
+			fail(err)
+		}
+	}
+
+	a, err := archive.Open(*Dir)
+	if err != nil {
+		fail(err)
+	}
+	ids, err := a.Ids()
+	if err != nil {
+		fail(err)
+	}
+	var ms []sla.Measure
+	for _, id := range ids {
+		v, err := a.Versions(id)
+		if err == archive.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", id, err))
+		}
+		t := v[len(v)-1]
+		created := secureWorks.TicketTime(t.DateCreated)
+		if (!from.IsZero() && created.Before(from)) || (!to.IsZero() && !created.Before(to)) {
+			continue
+		}
+		if len(*TicketTypes) > 0 && !contains(strings.Split(*TicketTypes, ","), t.TicketType) {
+			continue
+		}
+		ms = append(ms, sla.Compute(v, th))
+	}
+	r := sla.Build(ms, th, dims, time.Now())
+
+	switch {
+	case *Tickets == true:
+		printMeasures(ms, *Format)
+	case *Breaches == true:
+		printBreaches(r.Breaches, *Format)
+	case *Format == "json":
+		b, _ := json.MarshalIndent(r, "", "  ")
+		fmt.Printf("%s\n", b)
+	case *Format == "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"Dimension", "Key", "Tickets",
+			"FirstResponseCount", "FirstResponseMean", "FirstResponseP50", "FirstResponseP90", "FirstResponseP95", "FirstResponseP99", "FirstResponseMax",
+			"CloseCount", "CloseMean", "CloseP50", "CloseP90", "CloseP95", "CloseP99", "CloseMax",
+			"Reopens", "Reopened", "Breaches"})
+		row := func(dim string, g sla.Group) {
+			l := []string{dim, g.Key, strconv.Itoa(g.Tickets)}
+			for _, s := range []sla.Stats{g.FirstResponse, g.TimeToClose} {
+				l = append(l, strconv.Itoa(s.Count), seconds(s.Mean), seconds(s.P50), seconds(s.P90),
+					seconds(s.P95), seconds(s.P99), seconds(s.Max))
+			}
+			w.Write(append(l, strconv.Itoa(g.Reopens), strconv.Itoa(g.Reopened), strconv.Itoa(g.Breaches)))
+		}
+		row("all", r.Overall)
+		for _, d := range dims {
+			for _, g := range r.By[d] {
+				row(d, g)
+			}
+		}
+		w.Flush()
+	default:
+		fmt.Printf("SLA report: %d tickets, %d breaches (durations: p50/p90/p95/max)\n\n", r.Overall.Tickets, len(r.Breaches))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "\tTickets\tResponded\tFirst response\tClosed\tTime to close\tReopened\tBreaches\n")
+		row := func(name string, g sla.Group) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%s\t%d\t%d\n", name, g.Tickets, g.FirstResponse.Count, spread(g.FirstResponse),
+				g.TimeToClose.Count, spread(g.TimeToClose), g.Reopened, g.Breaches)
+		}
+		row("all", r.Overall)
+		for _, d := range dims {
+			/* Empty cells rather than an empty line, which would end tabwriter's column block */
+			fmt.Fprintf(w, "\t\t\t\t\t\t\t\nby %s\t\t\t\t\t\t\t\n", d)
+			for _, g := range r.By[d] {
+				row("  "+g.Key, g)
+			}
+		}
+		w.Flush()
+		if len(r.Breaches) > 0 {
+			fmt.Printf("\n")
+			printBreaches(r.Breaches, "text")
+		}
+	}
+}
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+func seconds(d sla.Duration) string {
+	return strconv.FormatInt(int64(time.Duration(d)/time.Second), 10)
+}
+
+/* short rounds a duration for the text tables */
+func short(d sla.Duration) string {
+	v := time.Duration(d)
+	switch {
+	case v >= time.Hour:
+		return v.Round(time.Minute).String()
+	case v >= time.Minute:
+		return v.Round(time.Second).String()
+	}
+	return v.String()
+}
+func spread(s sla.Stats) string {
+	if s.Count == 0 {
+		return "-"
+	}
+	return short(s.P50) + "/" + short(s.P90) + "/" + short(s.P95) + "/" + short(s.Max)
+}
+func printBreaches(l []sla.Breach, format string) {
+	switch format {
+	case "json":
+		b, _ := json.MarshalIndent(l, "", "  ")
+		fmt.Printf("%s\n", b)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"TicketId", "Kind", "Severity", "Client", "LimitSeconds", "ActualSeconds", "Open"})
+		for _, b := range l {
+			w.Write([]string{b.TicketId, b.Kind, b.Severity, b.Client, seconds(b.Limit), seconds(b.Actual),
+				strconv.FormatBool(b.Open)})
+		}
+		w.Flush()
+	default:
+		fmt.Printf("Breaches (%d)\n", len(l))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, b := range l {
+			open := ""
+			if b.Open {
+				open = "still open"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\tlimit %s\ttook %s\t%s\n", b.TicketId, b.Kind, b.Severity, b.Client,
+				short(b.Limit), short(b.Actual), open)
+		}
+		w.Flush()
+	}
+}
+func printMeasures(ms []sla.Measure, format string) {
+	if format == "json" {
+		b, _ := json.MarshalIndent(ms, "", "  ")
+		fmt.Printf("%s\n", b)
+		return
+	}
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"TicketId", "Severity", "Client", "Service", "ResponsibleParty", "Status", "Created",
+		"FirstResponseSeconds", "TimeToCloseSeconds", "Reopens"})
+	for _, m := range ms {
+		first, close := "", ""
+		if m.Responded {
+			first = seconds(m.FirstResponse)
+		}
+		if m.Closed {
+			close = seconds(m.TimeToClose)
+		}
+		w.Write([]string{m.TicketId, m.Severity, m.Client, m.Service, m.ResponsibleParty, m.Status,
+			m.Created.Format(time.RFC3339), first, close, strconv.Itoa(m.Reopens)})
+	}
+	w.Flush()
+}
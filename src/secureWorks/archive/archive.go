@@ -0,0 +1,217 @@
+package archive
+
+import "encoding/json"
+import "errors"
+import "net/url"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+/*
+ * Archive keeps every version of every ticket it is given, one JSON file
+ * per version, plus a checkpoint recording sync progress:
+ *
+ *   <dir>/tickets/<TicketId>/<TicketVersion>.json
+ *   <dir>/checkpoint.json
+ *   <dir>/index.json         search index, see index.go
+ *   <dir>/index.stale        present while index.json lags the tickets
+ *
+ * Files are written to a temporary name and renamed into place, so a
+ * crash never leaves a partial version behind.
+ */
+type Archive struct {
+	Dir   string
+	mu    sync.Mutex
+	idx   *index
+	stale bool
+}
+
+/* Checkpoint is the sync state persisted between runs */
+type Checkpoint struct {
+	LastSync time.Time `json:"lastSync"`
+	/* Tickets reported changed whose detail could not be fetched yet */
+	Pending []string `json:"pending,omitempty"`
+}
+
+var ErrNotFound = errors.New("ticket not in archive")
+
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "tickets"), 0700); err != nil {
+		return nil, err
+	}
+	return &Archive{Dir: dir}, nil
+}
+func (a *Archive) ticketDir(id string) string {
+	return filepath.Join(a.Dir, "tickets", url.PathEscape(id))
+}
+func (a *Archive) versionFile(id string, version string) string {
+	if len(version) == 0 {
+		version = "0"
+	}
+	return filepath.Join(a.ticketDir(id), url.PathEscape(version)+".json")
+}
+
+/* Has reports whether this version of the ticket is already stored */
+func (a *Archive) Has(id string, version string) bool {
+	_, err := os.Stat(a.versionFile(id, version))
+	return err == nil
+}
+
+/* Put stores t unless its version is already archived; added reports which */
+func (a *Archive) Put(t secureWorks.Ticket) (added bool, err error) {
+	if len(t.TicketId) == 0 {
+		return false, errors.New("ticket has no TicketId")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Has(t.TicketId, t.TicketVersion) {
+		return false, nil
+	}
+	/* Loaded first, so the marker below is not mistaken for an earlier crash */
+	idx, err := a.loadIndex()
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(a.ticketDir(t.TicketId), 0700); err != nil {
+		return false, err
+	}
+	if err := a.markIndexStale(); err != nil {
+		return false, err
+	}
+	if err := writeJSON(a.versionFile(t.TicketId, t.TicketVersion), t); err != nil {
+		return false, err
+	}
+
+	/* Only the latest version of a ticket is searchable */
+	d, ok := idx.Docs[t.TicketId]
+	if !ok || versionLess(secureWorks.Ticket{TicketVersion: d.Version, DateModified: d.DateModified}, t) {
+		idx.add(t)
+	}
+	return true, nil
+}
+
+/* Versions returns every stored version of a ticket, oldest first */
+func (a *Archive) Versions(id string) ([]secureWorks.Ticket, error) {
+	files, err := filepath.Glob(filepath.Join(a.ticketDir(id), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNotFound
+	}
+	var l []secureWorks.Ticket
+	for _, f := range files {
+		var t secureWorks.Ticket
+		if err := readJSON(f, &t); err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+	sort.Slice(l, func(i, j int) bool { return versionLess(l[i], l[j]) })
+	return l, nil
+}
+func (a *Archive) Version(id string, version string) (secureWorks.Ticket, error) {
+	var t secureWorks.Ticket
+	err := readJSON(a.versionFile(id, version), &t)
+	if os.IsNotExist(err) {
+		err = ErrNotFound
+	}
+	return t, err
+}
+func (a *Archive) Latest(id string) (secureWorks.Ticket, error) {
+	l, err := a.Versions(id)
+	if err != nil {
+		return secureWorks.Ticket{}, err
+	}
+	return l[len(l)-1], nil
+}
+
+/* Ids lists the archived ticket ids, sorted */
+func (a *Archive) Ids() ([]string, error) {
+	dirs, err := os.ReadDir(filepath.Join(a.Dir, "tickets"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range dirs {
+		if id, err := url.PathUnescape(d.Name()); err == nil && d.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+/* List returns the latest version of every archived ticket */
+func (a *Archive) List() ([]secureWorks.Ticket, error) {
+	ids, err := a.Ids()
+	if err != nil {
+		return nil, err
+	}
+	var l []secureWorks.Ticket
+	for _, id := range ids {
+		t, err := a.Latest(id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+	return l, nil
+}
+func (a *Archive) Checkpoint() (Checkpoint, error) {
+	var c Checkpoint
+	err := readJSON(filepath.Join(a.Dir, "checkpoint.json"), &c)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return c, err
+}
+
+/* SaveCheckpoint also writes out the search index */
+func (a *Archive) SaveCheckpoint(c Checkpoint) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.flushIndex(); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(a.Dir, "checkpoint.json"), c)
+}
+
+/* Numeric versions compare as numbers, anything else by DateModified */
+func versionLess(a secureWorks.Ticket, b secureWorks.Ticket) bool {
+	va, ea := strconv.ParseInt(a.TicketVersion, 10, 64)
+	vb, eb := strconv.ParseInt(b.TicketVersion, 10, 64)
+	if ea == nil && eb == nil && va != vb {
+		return va < vb
+	}
+	if a.DateModified != b.DateModified {
+		return a.DateModified < b.DateModified
+	}
+	return strings.Compare(a.TicketVersion, b.TicketVersion) < 0
+}
+func writeJSON(fileName string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+func readJSON(fileName string, v interface{}) error {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,47 @@
+package archive
+
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "testing"
+
+func TestIndexRebuiltAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Put(secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "1", SymptomDescription: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.stale")); !os.IsNotExist(err) {
+		t.Fatalf("index.stale left after Flush: %v", err)
+	}
+
+	/* A second run archives a ticket and dies before flushing */
+	b, _ := Open(dir)
+	if _, err := b.Put(secureWorks.Ticket{TicketId: "INC-2", TicketVersion: "1", SymptomDescription: "beaconing"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.stale")); err != nil {
+		t.Fatalf("no index.stale before Flush: %v", err)
+	}
+
+	c, _ := Open(dir)
+	r, err := c.Search("beaconing", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Ticket.TicketId != "INC-2" {
+		t.Fatalf("stale index not rebuilt: %+v", r)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.stale")); !os.IsNotExist(err) {
+		t.Errorf("index.stale left after the rebuild was flushed: %v", err)
+	}
+}
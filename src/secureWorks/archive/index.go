@@ -0,0 +1,237 @@
+package archive
+
+import "math"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "strings"
+import "unicode"
+
+/*
+ * index is an inverted index over the latest version of each archived
+ * ticket, kept in <dir>/index.json. Text terms map to the tickets and
+ * term frequencies they occur with; the structured fields searchable
+ * with field:value are kept per ticket. Put updates it in memory and
+ * SaveCheckpoint (or Flush) writes it out. Before its first new version
+ * since the last flush Put creates <dir>/index.stale, and the flush
+ * removes it, so an index left behind by a crash is rebuilt on the next
+ * load rather than silently missing tickets.
+ */
+type index struct {
+	Docs     map[string]indexDoc       `json:"docs"`
+	Postings map[string]map[string]int `json:"postings"`
+	dirty    bool
+}
+type indexDoc struct {
+	Version      string            `json:"version"`
+	DateCreated  int64             `json:"dateCreated"`
+	DateModified int64             `json:"dateModified"`
+	DateClosed   int64             `json:"dateClosed"`
+	Fields       map[string]string `json:"fields"`
+	Terms        []string          `json:"terms"`
+}
+
+/* Free text fields, by the name used in field-scoped queries */
+func textFields(t secureWorks.Ticket) map[string]string {
+	var w []string
+	for _, v := range t.WorkLogs {
+		w = append(w, v.Description)
+	}
+	return map[string]string{
+		"description": t.DetailedDescription,
+		"symptom":     t.SymptomDescription,
+		"reason":      t.Reason,
+		"worklog":     strings.Join(w, "\n"),
+	}
+}
+
+/* Exact-match fields, compared case-insensitively */
+func keywordFields(t secureWorks.Ticket) map[string]string {
+	return map[string]string{
+		"id":          t.TicketId,
+		"severity":    t.Severity,
+		"status":      t.Status,
+		"type":        t.TicketType,
+		"service":     t.Service,
+		"client":      t.Client.Name,
+		"contact":     t.Contact.Name,
+		"device":      t.Devices.Name,
+		"deviceip":    t.DeviceIp,
+		"alias":       t.DeviceAlias,
+		"location":    t.Location.Name,
+		"source":      t.EventSource,
+		"responsible": t.ResponsibleParty,
+	}
+}
+
+/*
+ * tokenize lowercases s and splits it into terms, keeping IPs, hostnames,
+ * hashes and similar dotted or dashed words whole and also indexing
+ * their parts, so both "10.0.0.5" and "evil" find "evil.example.com".
+ */
+func tokenize(s string) []string {
+	var terms []string
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-:/@", r)
+	})
+	for _, w := range words {
+		w = strings.Trim(w, "._-:/@")
+		if len(w) == 0 {
+			continue
+		}
+		terms = append(terms, w)
+		parts := strings.FieldsFunc(w, func(r rune) bool { return strings.ContainsRune("._-:/@", r) })
+		if len(parts) > 1 {
+			terms = append(terms, parts...)
+		}
+	}
+	return terms
+}
+
+/* words splits like tokenize but without the extra parts, for phrases */
+func words(s string) []string {
+	var l []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-:/@", r)
+	}) {
+		if w = strings.Trim(w, "._-:/@"); len(w) > 0 {
+			l = append(l, w)
+		}
+	}
+	return l
+}
+func (a *Archive) loadIndex() (*index, error) {
+	if a.idx != nil {
+		return a.idx, nil
+	}
+	if _, err := os.Stat(filepath.Join(a.Dir, "index.stale")); err == nil {
+		a.stale = true
+		return a.rebuildIndex()
+	}
+	idx := &index{}
+	err := readJSON(filepath.Join(a.Dir, "index.json"), idx)
+	if os.IsNotExist(err) {
+		return a.rebuildIndex()
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.idx = idx
+	return idx, nil
+}
+
+/* Reindex rebuilds the search index from the archived tickets */
+func (a *Archive) Reindex() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.rebuildIndex(); err != nil {
+		return err
+	}
+	return a.flushIndex()
+}
+func (a *Archive) rebuildIndex() (*index, error) {
+	idx := &index{Docs: map[string]indexDoc{}, Postings: map[string]map[string]int{}}
+	ids, err := a.Ids()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		t, err := a.Latest(id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx.add(t)
+	}
+	idx.dirty = true
+	a.idx = idx
+	return idx, nil
+}
+func (idx *index) add(t secureWorks.Ticket) {
+	idx.remove(t.TicketId)
+	tf := map[string]int{}
+	for _, v := range textFields(t) {
+		for _, term := range tokenize(v) {
+			tf[term]++
+		}
+	}
+	d := indexDoc{
+		Version:      t.TicketVersion,
+		DateCreated:  t.DateCreated,
+		DateModified: t.DateModified,
+		DateClosed:   t.DateClosed,
+		Fields:       map[string]string{},
+	}
+	for k, v := range keywordFields(t) {
+		if len(v) > 0 {
+			d.Fields[k] = strings.ToLower(v)
+		}
+	}
+	for term, n := range tf {
+		if idx.Postings[term] == nil {
+			idx.Postings[term] = map[string]int{}
+		}
+		idx.Postings[term][t.TicketId] = n
+		d.Terms = append(d.Terms, term)
+	}
+	idx.Docs[t.TicketId] = d
+	idx.dirty = true
+}
+func (idx *index) remove(id string) {
+	d, ok := idx.Docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range d.Terms {
+		delete(idx.Postings[term], id)
+		if len(idx.Postings[term]) == 0 {
+			delete(idx.Postings, term)
+		}
+	}
+	delete(idx.Docs, id)
+	idx.dirty = true
+}
+
+/* idf weighs rare terms (a specific IP) above common ones */
+func (idx *index) idf(term string) float64 {
+	return math.Log(1 + float64(len(idx.Docs))/float64(1+len(idx.Postings[term])))
+}
+
+/* Flush writes the search index if Put changed it */
+func (a *Archive) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.flushIndex()
+}
+func (a *Archive) flushIndex() error {
+	if a.idx == nil {
+		return nil
+	}
+	if a.idx.dirty {
+		if err := writeJSON(filepath.Join(a.Dir, "index.json"), a.idx); err != nil {
+			return err
+		}
+		a.idx.dirty = false
+	}
+	if a.stale {
+		if err := os.Remove(filepath.Join(a.Dir, "index.stale")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		a.stale = false
+	}
+	return nil
+}
+
+/* markIndexStale records that tickets are about to be written ahead of index.json */
+func (a *Archive) markIndexStale() error {
+	if a.stale {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(a.Dir, "index.stale"), nil, 0600); err != nil {
+		return err
+	}
+	a.stale = true
+	return nil
+}
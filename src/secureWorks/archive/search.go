@@ -0,0 +1,257 @@
+package archive
+
+import "fmt"
+import "secureWorks"
+import "sort"
+import "strings"
+import "time"
+
+/*
+ * Search queries are space separated clauses, all of which must match:
+ *
+ *   beacon 10.0.0.5          terms anywhere in the description, symptom,
+ *                            reason or worklogs
+ *   "lateral movement"       phrase
+ *   worklog:emotet           term or "phrase" in one text field
+ *                            (description, symptom, reason, worklog)
+ *   severity:HIGH device:fw01
+ *                            exact value of a ticket field (id, severity,
+ *                            status, type, service, client, contact,
+ *                            device, deviceip, alias, location, source,
+ *                            responsible)
+ *   created:2023-01-01..2023-06-30
+ *                            date range on created, modified or closed;
+ *                            either end may be left off
+ *   -status:CLOSED           any clause prefixed with - must not match
+ */
+type Result struct {
+	Ticket secureWorks.Ticket
+	Score  float64
+}
+type clause struct {
+	negate bool
+	field  string
+	value  string
+	phrase bool
+	from   time.Time
+	to     time.Time
+}
+
+var dateFields = map[string]bool{"created": true, "modified": true, "closed": true}
+var textFieldNames = map[string]bool{"description": true, "symptom": true, "reason": true, "worklog": true}
+
+func parseQuery(q string) ([]clause, error) {
+	var l []clause
+	for len(strings.TrimSpace(q)) > 0 {
+		q = strings.TrimLeft(q, " \t")
+		c := clause{}
+		if strings.HasPrefix(q, "-") {
+			c.negate = true
+			q = q[1:]
+		}
+		/* field: prefix, unless the colon is part of a value like an IPv6 address */
+		if i := strings.IndexAny(q, ": \""); i > 0 && q[i] == ':' {
+			f := strings.ToLower(q[:i])
+			if _, ok := keywordFields(secureWorks.Ticket{})[f]; ok || textFieldNames[f] || dateFields[f] {
+				c.field = f
+				q = q[i+1:]
+			}
+		}
+		if strings.HasPrefix(q, "\"") {
+			end := strings.Index(q[1:], "\"")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase: %s", q)
+			}
+			c.value = q[1 : end+1]
+			c.phrase = true
+			q = q[end+2:]
+		} else {
+			end := strings.IndexAny(q, " \t")
+			if end < 0 {
+				end = len(q)
+			}
+			c.value = q[:end]
+			q = q[end:]
+		}
+		if len(c.value) == 0 {
+			return nil, fmt.Errorf("empty value for %s:", c.field)
+		}
+		if dateFields[c.field] {
+			if err := c.parseRange(); err != nil {
+				return nil, err
+			}
+		}
+		l = append(l, c)
+	}
+	return l, nil
+}
+func (c *clause) parseRange() error {
+	from, to, isRange := strings.Cut(c.value, "..")
+	if !isRange {
+		to = from
+	}
+	var err error
+	if len(from) > 0 {
+		if c.from, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return fmt.Errorf("%s: dates are YYYY-MM-DD", c.field)
+		}
+	}
+	if len(to) > 0 {
+		if c.to, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return fmt.Errorf("%s: dates are YYYY-MM-DD", c.field)
+		}
+		/* the end date is inclusive */
+		c.to = c.to.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+/* Search returns archived tickets matching query, best match first */
+func (a *Archive) Search(query string, limit int) ([]Result, error) {
+	clauses, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	idx, err := a.loadIndex()
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	/* Narrow the candidates with the index, then check each one */
+	var candidates map[string]bool
+	for _, c := range clauses {
+		if c.negate || len(c.field) > 0 && !textFieldNames[c.field] {
+			continue
+		}
+		for _, w := range words(c.value) {
+			m := map[string]bool{}
+			for id := range idx.Postings[w] {
+				if candidates == nil || candidates[id] {
+					m[id] = true
+				}
+			}
+			candidates = m
+		}
+	}
+	if candidates == nil {
+		candidates = map[string]bool{}
+		for id := range idx.Docs {
+			candidates[id] = true
+		}
+	}
+
+	var results []Result
+	for id := range candidates {
+		d := idx.Docs[id]
+		var t *secureWorks.Ticket
+		ok := true
+		for _, c := range clauses {
+			m, err := c.match(a, id, d, &t)
+			if err != nil {
+				return nil, err
+			}
+			if m == c.negate {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if t == nil {
+			l, err := a.Latest(id)
+			if err != nil {
+				return nil, err
+			}
+			t = &l
+		}
+		results = append(results, Result{Ticket: *t, Score: idx.score(id, clauses)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Ticket.DateModified > results[j].Ticket.DateModified
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+/* match loads the ticket into *t only when a clause needs its text */
+func (c clause) match(a *Archive, id string, d indexDoc, t **secureWorks.Ticket) (bool, error) {
+	if dateFields[c.field] {
+		v := map[string]int64{"created": d.DateCreated, "modified": d.DateModified, "closed": d.DateClosed}[c.field]
+		if v == 0 {
+			return false, nil
+		}
+		tm := secureWorks.TicketTime(v)
+		return (c.from.IsZero() || !tm.Before(c.from)) && (c.to.IsZero() || tm.Before(c.to)), nil
+	}
+	if len(c.field) > 0 && !textFieldNames[c.field] {
+		return d.Fields[c.field] == strings.ToLower(c.value), nil
+	}
+
+	if *t == nil {
+		l, err := a.Latest(id)
+		if err != nil {
+			return false, err
+		}
+		*t = &l
+	}
+	want := words(c.value)
+	for name, text := range textFields(**t) {
+		if len(c.field) > 0 && name != c.field {
+			continue
+		}
+		if c.phrase || len(want) > 1 {
+			if containsSequence(words(text), want) {
+				return true, nil
+			}
+			continue
+		}
+		for _, term := range tokenize(text) {
+			if len(want) == 1 && term == want[0] {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+func containsSequence(s []string, sub []string) bool {
+	if len(sub) == 0 {
+		return false
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		j := 0
+		for j < len(sub) && s[i+j] == sub[j] {
+			j++
+		}
+		if j == len(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+/* score is tf-idf over the positive text clauses; phrases count double */
+func (idx *index) score(id string, clauses []clause) float64 {
+	var s float64
+	for _, c := range clauses {
+		if c.negate || len(c.field) > 0 && !textFieldNames[c.field] {
+			continue
+		}
+		for _, w := range words(c.value) {
+			tf := float64(idx.Postings[w][id])
+			if c.phrase {
+				tf *= 2
+			}
+			s += tf * idx.idf(w)
+		}
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package archive
+
+import "reflect"
+import "secureWorks"
+import "testing"
+import "time"
+
+func TestTokenize(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []string
+	}{
+		{"Beacon to 10.0.0.5, again.", []string{"beacon", "to", "10.0.0.5", "10", "0", "0", "5", "again"}},
+		{"host evil.example.com:443", []string{"host", "evil.example.com:443", "evil", "example", "com", "443"}},
+		{"user@corp (admin)", []string{"user@corp", "user", "corp", "admin"}},
+		{"--- ... ", nil},
+	} {
+		if got := tokenize(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	if got := words("Lateral-movement via 10.0.0.5."); !reflect.DeepEqual(got, []string{"lateral-movement", "via", "10.0.0.5"}) {
+		t.Errorf("words: %q", got)
+	}
+}
+func TestParseQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return d
+	}
+	for _, c := range []struct {
+		in   string
+		want []clause
+	}{
+		{`beacon 10.0.0.5`, []clause{{value: "beacon"}, {value: "10.0.0.5"}}},
+		{`"lateral movement"`, []clause{{value: "lateral movement", phrase: true}}},
+		{`worklog:"c2 traffic" -Status:CLOSED`, []clause{
+			{field: "worklog", value: "c2 traffic", phrase: true},
+			{field: "status", value: "CLOSED", negate: true}}},
+		/* not a known field, so the colon stays in the value */
+		{`fe80::1 url:http`, []clause{{value: "fe80::1"}, {value: "url:http"}}},
+		{`created:2023-01-01..2023-06-30`, []clause{{field: "created", value: "2023-01-01..2023-06-30",
+			from: day("2023-01-01"), to: day("2023-07-01")}}},
+		{`closed:..2023-01-31 modified:2023-02-01`, []clause{
+			{field: "closed", value: "..2023-01-31", to: day("2023-02-01")},
+			{field: "modified", value: "2023-02-01", from: day("2023-02-01"), to: day("2023-02-02")}}},
+		{"  \t ", nil},
+	} {
+		got, err := parseQuery(c.in)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseQuery(%q)\n got %+v\nwant %+v", c.in, got, c.want)
+		}
+	}
+	for _, in := range []string{`"open phrase`, `severity:`, `created:2023-13-01`, `closed:yesterday..`} {
+		if _, err := parseQuery(in); err == nil {
+			t.Errorf("parseQuery(%q): no error", in)
+		}
+	}
+}
+func TestSearch(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []secureWorks.Ticket{
+		{TicketId: "INC-1", TicketVersion: "1", Severity: "HIGH", Status: "OPEN",
+			SymptomDescription: "Lateral movement from 10.0.0.5"},
+		{TicketId: "INC-2", TicketVersion: "1", Severity: "LOW", Status: "CLOSED",
+			SymptomDescription: "movement lateral", WorkLogs: []secureWorks.WorkLog{{Description: "10.0.0.5 10.0.0.5 again"}}},
+		/* an older version is not searchable once a newer one exists */
+		{TicketId: "INC-1", TicketVersion: "0", SymptomDescription: "emotet"},
+	} {
+		if _, err := a.Put(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		query string
+		want  []string
+	}{
+		{`10.0.0.5`, []string{"INC-2", "INC-1"}},
+		{`"lateral movement"`, []string{"INC-1"}},
+		{`10.0.0.5 -status:closed`, []string{"INC-1"}},
+		{`severity:low`, []string{"INC-2"}},
+		{`worklog:10.0.0.5`, []string{"INC-2"}},
+		{`emotet`, nil},
+	} {
+		r, err := a.Search(c.query, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		var got []string
+		for _, v := range r {
+			got = append(got, v.Ticket.TicketId)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Search(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package archive
+
+import "errors"
+import "fmt"
+import "secureWorks"
+import "sort"
+import "time"
+
+type SyncOptions struct {
+	TicketTypes        []string
+	Worklogs           string
+	Limit              int
+	AssignedToCustomer int
+}
+type SyncResult struct {
+	/* Versions newly written to the archive */
+	Added []secureWorks.Ticket
+	/* Tickets whose detail fetch failed; retried on the next Sync */
+	Errors map[string]error
+}
+
+/*
+ * Sync polls GetUpdates for each ticket type and fetches GetTicketDetail
+ * for every ticket whose reported version is not archived yet, plus any
+ * left pending by a previous run. Versions already stored are never
+ * fetched again, so an interrupted sync resumes where it stopped. A
+ * ticket type whose GetUpdates fails does not stop the others; its error
+ * is returned once the rest is archived and the checkpoint saved.
+ */
+func Sync(q secureWorks.Query, a *Archive, o SyncOptions) (SyncResult, error) {
+	r := SyncResult{Errors: map[string]error{}}
+	cp, err := a.Checkpoint()
+	if err != nil {
+		return r, err
+	}
+	if len(o.TicketTypes) == 0 {
+		o.TicketTypes = []string{"INCIDENT"}
+	}
+	if len(o.Worklogs) == 0 {
+		o.Worklogs = "ALL"
+	}
+	if o.Limit == 0 {
+		o.Limit = 500
+	}
+
+	fetch := map[string]bool{}
+	for _, id := range cp.Pending {
+		fetch[id] = true
+	}
+	var errs []error
+	for _, tt := range o.TicketTypes {
+		u, err := secureWorks.GetUpdates(q, tt, o.Worklogs, o.Limit, o.AssignedToCustomer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("GetUpdates %s: %w", tt, err))
+			continue
+		}
+		for _, t := range u.Tickets {
+			if !a.Has(t.TicketId, t.TicketVersion) {
+				fetch[t.TicketId] = true
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(fetch))
+	for id := range fetch {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var pending []string
+	for _, id := range ids {
+		d, err := secureWorks.GetTicketDetail(q, id)
+		if err == nil {
+			var added bool
+			if added, err = a.Put(d.Detail); added {
+				r.Added = append(r.Added, d.Detail)
+			}
+		}
+		if err != nil {
+			r.Errors[id] = err
+			pending = append(pending, id)
+		}
+	}
+
+	cp.LastSync = time.Now()
+	cp.Pending = pending
+	return r, errors.Join(append(errs, a.SaveCheckpoint(cp))...)
+}
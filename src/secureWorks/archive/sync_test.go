@@ -0,0 +1,66 @@
+package archive
+
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "regexp"
+import "secureWorks"
+import "strings"
+import "testing"
+
+/*
+ * soapServer answers getUpdates with one ticket per type (a fault for
+ * CHANGE) and getTicketDetail with that ticket at version 1.
+ */
+func soapServer(t *testing.T) *httptest.Server {
+	tag := func(name string, body string) string {
+		m := regexp.MustCompile(`<` + name + `>([^<]*)</` + name + `>`).FindStringSubmatch(body)
+		if m == nil {
+			return ""
+		}
+		return m[1]
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body := string(b)
+		switch {
+		case strings.Contains(body, "getUpdates") && tag("ticketType", body) == "CHANGE":
+			io.WriteString(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Server</faultcode><faultstring>unavailable</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+		case strings.Contains(body, "getUpdates"):
+			io.WriteString(w, `<Envelope><Body><getUpdatesResponse><ticket><ticketId>`+tag("ticketType", body)+
+				`-1</ticketId><ticketVersion>1</ticketVersion></ticket></getUpdatesResponse></Body></Envelope>`)
+		case strings.Contains(body, "getTicketDetail"):
+			io.WriteString(w, `<Envelope><Body><getTicketDetailResponse><ticketDetail><ticketId>`+tag("ticketId", body)+
+				`</ticketId><ticketVersion>1</ticketVersion></ticketDetail></getTicketDetailResponse></Body></Envelope>`)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+func TestSyncFailedTicketType(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := secureWorks.Query{ApiUri: soapServer(t).URL}
+	r, err := Sync(q, a, SyncOptions{TicketTypes: []string{"INCIDENT", "CHANGE", "SERVICE_REQUEST"}})
+	if err == nil || !strings.Contains(err.Error(), "GetUpdates CHANGE") {
+		t.Errorf("failed ticket type not reported: %v", err)
+	}
+	/* the types before and after the failing one are still archived */
+	var got []string
+	for _, v := range r.Added {
+		got = append(got, v.TicketId)
+	}
+	if strings.Join(got, ",") != "INCIDENT-1,SERVICE_REQUEST-1" {
+		t.Errorf("added %q", got)
+	}
+	cp, err := a.Checkpoint()
+	if err != nil || cp.LastSync.IsZero() || len(cp.Pending) != 0 {
+		t.Errorf("checkpoint %+v, %v", cp, err)
+	}
+	if !a.Has("INCIDENT-1", "1") || !a.Has("SERVICE_REQUEST-1", "1") {
+		t.Error("tickets not archived")
+	}
+}
@@ -0,0 +1,52 @@
+package secureWorks
+
+import "context"
+import "sync"
+
+/* TicketResult is the outcome of fetching one id in GetTicketDetails */
+type TicketResult struct {
+	TicketId string
+	Ticket   Ticket
+	Err      error
+}
+
+/*
+ * GetTicketDetails fetches the detail of every id with a pool of workers
+ * (1 if < 1), under q's RateLimit. Results are in the order of ids, one
+ * per id, each with its own error; once ctx is done the ids not yet
+ * fetched get ctx's error.
+ */
+func GetTicketDetails(ctx context.Context, q Query, ids []string, workers int) []TicketResult {
+	if workers < 1 {
+		workers = 1
+	}
+	q = q.WithContext(ctx)
+	r := make([]TicketResult, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r[i].TicketId = ids[i]
+				if err := ctx.Err(); err != nil {
+					r[i].Err = err
+					continue
+				}
+				x, err := GetTicketDetail(q, ids[i])
+				if err != nil {
+					r[i].Err = err
+					continue
+				}
+				r[i].Ticket = x.Detail
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return r
+}
@@ -0,0 +1,102 @@
+package secureWorks
+
+import "context"
+import "fmt"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "regexp"
+import "strconv"
+import "strings"
+import "sync"
+import "sync/atomic"
+import "testing"
+import "time"
+
+/*
+ * detailServer answers getTicketDetail with the ticket asked for, faulting
+ * for ids starting BAD. Lower numbered ids answer later, so workers finish
+ * out of order.
+ */
+func detailServer(t *testing.T, calls *int32, peak *int32) *httptest.Server {
+	idRe := regexp.MustCompile(`<ticketId>([^<]*)</ticketId>`)
+	var mu sync.Mutex
+	var inFlight int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		mu.Lock()
+		inFlight++
+		if inFlight > atomic.LoadInt32(peak) {
+			atomic.StoreInt32(peak, inFlight)
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		b, _ := io.ReadAll(r.Body)
+		id := string(idRe.FindSubmatch(b)[1])
+		if strings.HasPrefix(id, "BAD") {
+			io.WriteString(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Server</faultcode><faultstring>no such ticket `+id+`</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+		if v, err := strconv.Atoi(strings.TrimPrefix(id, "INC-")); err == nil {
+			time.Sleep(time.Duration(10-v) * 5 * time.Millisecond)
+		}
+		io.WriteString(w, `<Envelope><Body><getTicketDetailResponse><ticketDetail><ticketId>`+id+
+			`</ticketId></ticketDetail></getTicketDetailResponse></Body></Envelope>`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+func TestGetTicketDetails(t *testing.T) {
+	var calls, peak int32
+	s := detailServer(t, &calls, &peak)
+	var ids []string
+	for n := 1; n <= 8; n++ {
+		ids = append(ids, fmt.Sprintf("INC-%d", n))
+	}
+	ids = append(ids[:3], append([]string{"BAD-1"}, ids[3:]...)...)
+	ids = append(ids, "BAD-2", "INC-1")
+
+	r := GetTicketDetails(context.Background(), Query{ApiUri: s.URL}, ids, 4)
+	if len(r) != len(ids) {
+		t.Fatalf("%d results for %d ids", len(r), len(ids))
+	}
+	for n, id := range ids {
+		got := r[n]
+		if got.TicketId != id {
+			t.Errorf("result %d is %s, want %s", n, got.TicketId, id)
+		}
+		if strings.HasPrefix(id, "BAD") {
+			if got.Err == nil || !strings.Contains(got.Err.Error(), "no such ticket "+id) {
+				t.Errorf("%s: error %v", id, got.Err)
+			}
+		} else if got.Err != nil || got.Ticket.TicketId != id {
+			t.Errorf("%s: %+v", id, got)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != int32(len(ids)) {
+		t.Errorf("%d calls for %d ids", n, len(ids))
+	}
+	if p := atomic.LoadInt32(&peak); p < 2 || p > 4 {
+		t.Errorf("%d requests at once with 4 workers", p)
+	}
+}
+func TestGetTicketDetailsCancelled(t *testing.T) {
+	var calls, peak int32
+	s := detailServer(t, &calls, &peak)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := GetTicketDetails(ctx, Query{ApiUri: s.URL}, []string{"INC-1", "INC-2", "INC-3"}, 0)
+	for n, id := range []string{"INC-1", "INC-2", "INC-3"} {
+		if r[n].TicketId != id || r[n].Err != context.Canceled {
+			t.Errorf("result %d: %+v", n, r[n])
+		}
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("%d calls after cancel", calls)
+	}
+}
@@ -0,0 +1,46 @@
+package secureWorks
+
+import "bytes"
+import "encoding/xml"
+import "strings"
+
+/*
+ * Call runs any operation from typed request/response structs, for the
+ * bindings generated from the WSDL (see wsdl/ and ticketing/). request's
+ * fields are marshalled as the children of <ser:operation>, after the
+ * credentials; response is unmarshalled from the first element of the
+ * SOAP Body, so it needs an XMLName naming the response element.
+ */
+func Call(q Query, operation string, request interface{}, response interface{}) error {
+	var b bytes.Buffer
+	if request != nil {
+		if err := xml.NewEncoder(&b).EncodeElement(request, xml.StartElement{Name: xml.Name{Local: "body"}}); err != nil {
+			return err
+		}
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(b.String(), "<body>"), "</body>")
+	SOAPxml := `
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
+   ` + soapHeader(q) + `
+   <soapenv:Body>
+      <ser:` + operation + `>` + credentials(q) + `
+         ` + body + `
+      </ser:` + operation + `>
+   </soapenv:Body>
+</soapenv:Envelope>
+`
+	x := new(callEnvelope)
+	if _, err := makeSOAPrequest(q, operation, SOAPxml, x); err != nil {
+		return err
+	}
+	if response == nil {
+		return nil
+	}
+	return q.redactError(xml.Unmarshal(x.Body.Content, response))
+}
+
+type callEnvelope struct {
+	Body struct {
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
@@ -0,0 +1,505 @@
+package secureWorks
+
+import "bytes"
+import "encoding/json"
+import "encoding/xml"
+import "errors"
+import "fmt"
+import "net/url"
+import "os"
+import "path/filepath"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+
+/*
+ * Config files come in XML, JSON or YAML (by extension, XML otherwise).
+ * Settings at the top level apply to every profile; a named profile
+ * overrides them. Environment variables SECUREWORKS_<FIELD> (e.g.
+ * SECUREWORKS_APIURI) override both.
+ *
+ *   <Config>
+ *     <ApiUri>https://ws.secureworks.com/api/TicketingService</ApiUri>
+ *     <UserName>Username</UserName>
+ *     <Password>Password</Password>
+ *     <Profile Name="acme">
+ *       <ClientId>1234</ClientId>
+ *       <LocationId>5678</LocationId>
+ *     </Profile>
+ *   </Config>
+ */
+const ProfileEnv = "SECUREWORKS_PROFILE"
+
+/* Settings recognised in config files and as SECUREWORKS_<FIELD> */
+var ConfigFields = []string{"UserName", "Password", "ClientId", "LocationId", "ApiUri",
+	"WSSecurity", "Retries", "RateLimit", "SensitiveFields",
+	"PasswordEnv", "PasswordFile", "PasswordCommand", "EncryptedPassword",
+	"DeviceCache", "DeviceCacheMaxAge"}
+
+type settings map[string]string
+type configFile struct {
+	Defaults settings
+	Profiles map[string]settings
+}
+
+/*
+ * ReadConfig loads fileName using the profile named by
+ * $SECUREWORKS_PROFILE, or the top level settings if that is unset.
+ */
+func ReadConfig(fileName string) (Query, error) {
+	return ReadConfigProfile(fileName, "")
+}
+func ReadConfigProfile(fileName string, profile string) (Query, error) {
+	q := Query{}
+	c, err := loadConfigFile(fileName)
+	if err != nil {
+		return q, err
+	}
+	if len(profile) == 0 {
+		profile = os.Getenv(ProfileEnv)
+	}
+
+	s := settings{}
+	for k, v := range c.Defaults {
+		s[k] = v
+	}
+	if len(profile) > 0 {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return q, fmt.Errorf("%s: no profile %q", fileName, profile)
+		}
+		for k, v := range p {
+			s[k] = v
+		}
+	}
+	for _, f := range ConfigFields {
+		if v, ok := os.LookupEnv("SECUREWORKS_" + strings.ToUpper(f)); ok {
+			s[f] = v
+		}
+	}
+
+	if err := s.apply(&q); err != nil {
+		return q, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if err := q.ResolvePassword(); err != nil {
+		return q, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if err := q.Validate(); err != nil {
+		return q, fmt.Errorf("%s: %v", fileName, err)
+	}
+	q = q.WithRateLimit(q.RateLimit)
+	if len(q.DeviceCache) > 0 {
+		maxAge, _ := time.ParseDuration(q.DeviceCacheMaxAge)
+		q.Inventory = NewInventory(q.DeviceCache, maxAge)
+	}
+	return q, nil
+}
+
+/* ConfigProfiles lists the profile names defined in fileName */
+func ConfigProfiles(fileName string) ([]string, error) {
+	c, err := loadConfigFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for k := range c.Profiles {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+/* Validate reports every missing or malformed required setting */
+func (q Query) Validate() error {
+	var errs []error
+	if len(q.ApiUri) == 0 {
+		errs = append(errs, errors.New("ApiUri is required"))
+	} else if u, err := url.Parse(q.ApiUri); err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		errs = append(errs, fmt.Errorf("ApiUri %q is not an http(s) URL", q.ApiUri))
+	}
+	if len(q.UserName) == 0 {
+		errs = append(errs, errors.New("UserName is required"))
+	}
+	if len(q.Password) == 0 {
+		errs = append(errs, errors.New("Password is required"))
+	}
+	if q.Retries < 0 {
+		errs = append(errs, errors.New("Retries must not be negative"))
+	}
+	if q.RateLimit < 0 {
+		errs = append(errs, errors.New("RateLimit must not be negative"))
+	}
+	if len(q.DeviceCacheMaxAge) > 0 {
+		if d, err := time.ParseDuration(q.DeviceCacheMaxAge); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("DeviceCacheMaxAge %q is not a duration like 1h", q.DeviceCacheMaxAge))
+		}
+	}
+	return errors.Join(errs...)
+}
+func (s settings) apply(q *Query) error {
+	for k, v := range s {
+		v = strings.TrimSpace(v)
+		switch k {
+		case "UserName":
+			q.UserName = v
+		case "Password":
+			q.Password = v
+		case "ClientId":
+			q.ClientId = v
+		case "LocationId":
+			q.LocationId = v
+		case "ApiUri":
+			q.ApiUri = v
+		case "WSSecurity":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("WSSecurity: %q is not true or false", v)
+			}
+			q.WSSecurity = b
+		case "Retries":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("Retries: %q is not a number", v)
+			}
+			q.Retries = n
+		case "RateLimit":
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("RateLimit: %q is not a number (requests per second)", v)
+			}
+			q.RateLimit = n
+		case "PasswordEnv":
+			q.PasswordEnv = v
+		case "PasswordFile":
+			q.PasswordFile = v
+		case "PasswordCommand":
+			q.PasswordCommand = v
+		case "EncryptedPassword":
+			q.EncryptedPassword = v
+		case "DeviceCache":
+			q.DeviceCache = v
+		case "DeviceCacheMaxAge":
+			q.DeviceCacheMaxAge = v
+		case "SensitiveFields":
+			q.SensitiveFields = nil
+			for _, f := range strings.Split(v, ",") {
+				if f = strings.TrimSpace(f); len(f) > 0 {
+					q.SensitiveFields = append(q.SensitiveFields, f)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown setting %q", k)
+		}
+	}
+	return nil
+}
+func loadConfigFile(fileName string) (*configFile, error) {
+	r, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		/* Numbers as written: 1234567 rather than float64's 1.234567e+06 */
+		d := json.NewDecoder(bytes.NewReader(r))
+		d.UseNumber()
+		if err = d.Decode(&m); err == nil && d.More() {
+			err = errors.New("unexpected data after the JSON object")
+		}
+	case ".yaml", ".yml":
+		m, err = parseYAML(string(r))
+	default:
+		m, err = parseXMLConfig(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	c := &configFile{Defaults: settings{}, Profiles: map[string]settings{}}
+	for k, v := range m {
+		if k != "Profiles" {
+			c.Defaults[k] = settingString(v)
+			continue
+		}
+		profiles, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: Profiles must be a map of profile names", fileName)
+		}
+		for name, pv := range profiles {
+			fields, ok := pv.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: profile %q must be a map of settings", fileName, name)
+			}
+			c.Profiles[name] = settings{}
+			for fk, fv := range fields {
+				c.Profiles[name][fk] = settingString(fv)
+			}
+		}
+	}
+	return c, nil
+}
+
+/* Lists (SensitiveFields) are kept comma separated */
+func settingString(v interface{}) string {
+	switch t := v.(type) {
+	case []interface{}:
+		var l []string
+		for _, e := range t {
+			l = append(l, fmt.Sprint(e))
+		}
+		return strings.Join(l, ",")
+	case []string:
+		return strings.Join(t, ",")
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+type xmlConfigField struct {
+	XMLName xml.Name
+	Value   string   `xml:",chardata"`
+	Items   []string `xml:"Field"`
+}
+type xmlConfigProfile struct {
+	Name     string             `xml:"Name,attr"`
+	Fields   []xmlConfigField   `xml:",any"`
+	Profiles []xmlConfigProfile `xml:"Profile"`
+}
+
+func parseXMLConfig(r []byte) (map[string]interface{}, error) {
+	var c xmlConfigProfile
+	if err := xml.Unmarshal(r, &c); err != nil {
+		return nil, err
+	}
+	fields := func(p xmlConfigProfile) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, f := range p.Fields {
+			if len(f.Items) > 0 {
+				m[f.XMLName.Local] = f.Items
+			} else {
+				m[f.XMLName.Local] = f.Value
+			}
+		}
+		return m
+	}
+	m := fields(c)
+	if len(c.Profiles) > 0 {
+		profiles := map[string]interface{}{}
+		for _, p := range c.Profiles {
+			if len(p.Name) == 0 {
+				return nil, errors.New("<Profile> without a Name attribute")
+			}
+			profiles[p.Name] = fields(p)
+		}
+		m["Profiles"] = profiles
+	}
+	return m, nil
+}
+
+/*
+ * parseYAML handles the subset of YAML a config file needs: nested maps
+ * by indentation, scalar values (optionally quoted), "- item" lists and
+ * # comments.
+ */
+func parseYAML(s string) (map[string]interface{}, error) {
+	type line struct {
+		n      int
+		indent int
+		text   string
+	}
+	var lines []line
+	for i, l := range strings.Split(s, "\n") {
+		t := strings.TrimRight(l, " \t\r")
+		trimmed := strings.TrimLeft(t, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, line{i + 1, len(t) - len(trimmed), trimmed})
+	}
+
+	unquote := func(v string) string {
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+			end := 0
+			for i := 1; i < len(v); i++ {
+				if v[0] == '"' && v[i] == '\\' {
+					i++
+				} else if v[i] == v[0] {
+					end = i
+					break
+				}
+			}
+			if end > 0 {
+				v = v[:end+1]
+				if u, err := strconv.Unquote(v); err == nil && v[0] == '"' {
+					return u
+				}
+				return v[1 : len(v)-1]
+			}
+		}
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = strings.TrimSpace(v[:i])
+		}
+		return v
+	}
+
+	var parseMap func(i int, indent int) (map[string]interface{}, int, error)
+	parseMap = func(i int, indent int) (map[string]interface{}, int, error) {
+		m := map[string]interface{}{}
+		for i < len(lines) && lines[i].indent >= indent {
+			l := lines[i]
+			if l.indent != indent {
+				return nil, i, fmt.Errorf("yaml line %d: unexpected indentation", l.n)
+			}
+			colon := strings.Index(l.text, ":")
+			if colon <= 0 {
+				return nil, i, fmt.Errorf("yaml line %d: expected key: value", l.n)
+			}
+			key := unquote(strings.TrimSpace(l.text[:colon]))
+			value := strings.TrimSpace(l.text[colon+1:])
+			i++
+			if len(value) > 0 {
+				m[key] = unquote(value)
+				continue
+			}
+			if i < len(lines) && lines[i].indent >= indent && strings.HasPrefix(lines[i].text, "- ") {
+				var list []interface{}
+				itemIndent := lines[i].indent
+				for i < len(lines) && lines[i].indent == itemIndent && strings.HasPrefix(lines[i].text, "- ") {
+					list = append(list, unquote(strings.TrimSpace(lines[i].text[2:])))
+					i++
+				}
+				m[key] = list
+				continue
+			}
+			if i < len(lines) && lines[i].indent > indent {
+				sub, next, err := parseMap(i, lines[i].indent)
+				if err != nil {
+					return nil, next, err
+				}
+				m[key] = sub
+				i = next
+				continue
+			}
+			m[key] = ""
+		}
+		return m, i, nil
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	m, i, err := parseMap(0, lines[0].indent)
+	if err == nil && i < len(lines) {
+		err = fmt.Errorf("yaml line %d: unexpected indentation", lines[i].n)
+	}
+	return m, err
+}
+
+/*
+ * WriteConfig writes q's config fields to fileName, in the format its
+ * extension names as ReadConfig does (XML unless .json, .yaml or .yml),
+ * readable only by the owner since it may hold the password. The file is
+ * replaced by rename, so an existing one never keeps looser permissions
+ * or is left half written. Password is written only if set: clear it
+ * when another password source is configured.
+ */
+func WriteConfig(fileName string, q Query) error {
+	type field struct {
+		name  string
+		value string
+		raw   bool
+	}
+	var fields []field
+	add := func(name string, value string, raw bool) {
+		if len(value) > 0 {
+			fields = append(fields, field{name, value, raw})
+		}
+	}
+	add("UserName", q.UserName, false)
+	add("Password", q.Password, false)
+	add("PasswordEnv", q.PasswordEnv, false)
+	add("PasswordFile", q.PasswordFile, false)
+	add("PasswordCommand", q.PasswordCommand, false)
+	add("EncryptedPassword", q.EncryptedPassword, false)
+	add("ClientId", q.ClientId, false)
+	add("LocationId", q.LocationId, false)
+	add("ApiUri", q.ApiUri, false)
+	add("DeviceCache", q.DeviceCache, false)
+	add("DeviceCacheMaxAge", q.DeviceCacheMaxAge, false)
+	if q.WSSecurity {
+		add("WSSecurity", "true", true)
+	}
+	if q.Retries > 0 {
+		add("Retries", strconv.Itoa(q.Retries), true)
+	}
+	if q.RateLimit > 0 {
+		add("RateLimit", strconv.FormatFloat(q.RateLimit, 'f', -1, 64), true)
+	}
+
+	var b strings.Builder
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		quote := func(v string) string {
+			j, _ := json.Marshal(v)
+			return string(j)
+		}
+		var l []string
+		for _, f := range fields {
+			if f.raw {
+				l = append(l, "  "+quote(f.name)+": "+f.value)
+			} else {
+				l = append(l, "  "+quote(f.name)+": "+quote(f.value))
+			}
+		}
+		if len(q.SensitiveFields) > 0 {
+			var items []string
+			for _, f := range q.SensitiveFields {
+				items = append(items, quote(f))
+			}
+			l = append(l, `  "SensitiveFields": [`+strings.Join(items, ", ")+"]")
+		}
+		b.WriteString("{\n" + strings.Join(l, ",\n") + "\n}\n")
+	case ".yaml", ".yml":
+		for _, f := range fields {
+			if f.raw {
+				b.WriteString(f.name + ": " + f.value + "\n")
+			} else {
+				b.WriteString(f.name + ": " + strconv.Quote(f.value) + "\n")
+			}
+		}
+		if len(q.SensitiveFields) > 0 {
+			b.WriteString("SensitiveFields:\n")
+			for _, f := range q.SensitiveFields {
+				b.WriteString("  - " + strconv.Quote(f) + "\n")
+			}
+		}
+	default:
+		b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n<Config>\n")
+		for _, f := range fields {
+			b.WriteString("  <" + f.name + ">" + xmlEscape(f.value) + "</" + f.name + ">\n")
+		}
+		if len(q.SensitiveFields) > 0 {
+			b.WriteString("  <SensitiveFields>\n")
+			for _, f := range q.SensitiveFields {
+				b.WriteString("    <Field>" + xmlEscape(f) + "</Field>\n")
+			}
+			b.WriteString("  </SensitiveFields>\n")
+		}
+		b.WriteString("</Config>\n")
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	/* WriteFile keeps the mode of a leftover tmp file */
+	if err := os.Chmod(tmp, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
@@ -0,0 +1,129 @@
+package secureWorks
+
+import "os"
+import "path/filepath"
+import "reflect"
+import "testing"
+
+func writeTestConfig(t *testing.T, name string, content string) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+func TestParseYAML(t *testing.T) {
+	m, err := parseYAML(`---
+# comment
+ApiUri: https://ws.example.com/api   # trailing comment
+UserName: "jdoe"
+Password: 'p#ss: word'
+SensitiveFields:
+  - clientId
+  - "locationId"
+Profiles:
+  acme:
+    ClientId: 1234
+    LocationId: "0042"
+  empty:
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ApiUri":          "https://ws.example.com/api",
+		"UserName":        "jdoe",
+		"Password":        "p#ss: word",
+		"SensitiveFields": []interface{}{"clientId", "locationId"},
+		"Profiles": map[string]interface{}{
+			"acme":  map[string]interface{}{"ClientId": "1234", "LocationId": "0042"},
+			"empty": "",
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("parseYAML\n got %#v\nwant %#v", m, want)
+	}
+}
+func TestParseYAMLErrors(t *testing.T) {
+	for _, s := range []string{
+		"a: 1\n\tb: 2\n",
+		"a: 1\n   b: 2\n",
+		"a:\n  b: 1\n c: 2\n",
+		"just text\n",
+	} {
+		if _, err := parseYAML(s); err == nil {
+			t.Errorf("parseYAML(%q): no error", s)
+		}
+	}
+}
+func TestReadConfigJSONNumbers(t *testing.T) {
+	fileName := writeTestConfig(t, "c.json", `{"ApiUri": "https://ws.example.com/api", "UserName": "u", "Password": "p",
+ "RateLimit": 0.5, "Retries": 3, "Profiles": {"big": {"ClientId": 1234567, "LocationId": 100000000000}}}`)
+	q, err := ReadConfigProfile(fileName, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ClientId != "1234567" || q.LocationId != "100000000000" {
+		t.Errorf("ClientId %q LocationId %q", q.ClientId, q.LocationId)
+	}
+	if q.RateLimit != 0.5 || q.Retries != 3 {
+		t.Errorf("RateLimit %v Retries %v", q.RateLimit, q.Retries)
+	}
+	if _, err := ReadConfig(writeTestConfig(t, "d.json", `{"ApiUri": "x"} {}`)); err == nil {
+		t.Error("trailing data accepted")
+	}
+}
+func TestReadConfigEnvOverrides(t *testing.T) {
+	fileName := writeTestConfig(t, "c.yaml", `ApiUri: https://ws.example.com/api
+UserName: u
+Password: p
+ClientId: 1
+Profiles:
+  acme:
+    ClientId: 2
+    LocationId: 3
+`)
+	t.Setenv(ProfileEnv, "acme")
+	t.Setenv("SECUREWORKS_LOCATIONID", "9")
+	t.Setenv("SECUREWORKS_SENSITIVEFIELDS", "a, b")
+	q, err := ReadConfig(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ClientId != "2" || q.LocationId != "9" || !reflect.DeepEqual(q.SensitiveFields, []string{"a", "b"}) {
+		t.Errorf("ClientId %q LocationId %q SensitiveFields %q", q.ClientId, q.LocationId, q.SensitiveFields)
+	}
+
+	t.Setenv("SECUREWORKS_RETRIES", "many")
+	if _, err := ReadConfig(fileName); err == nil {
+		t.Error("malformed Retries from the environment accepted")
+	}
+	t.Setenv("SECUREWORKS_RETRIES", "1")
+	if _, err := ReadConfigProfile(fileName, "nope"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+func TestWriteConfigRoundTrip(t *testing.T) {
+	q := Query{ApiUri: "https://ws.example.com/api", UserName: `j"doe<&>`, Password: "p#ss: 'word'",
+		ClientId: "0042", LocationId: "7", WSSecurity: true, Retries: 3, RateLimit: 0.5,
+		SensitiveFields: []string{"clientId", "deviceIp"}, DeviceCache: "devices.json", DeviceCacheMaxAge: "6h"}
+	for _, name := range []string{"c.xml", "c.json", "c.yaml", "c.yml", "c.conf"} {
+		fileName := filepath.Join(t.TempDir(), name)
+		if err := WriteConfig(fileName, q); err != nil {
+			t.Fatal(err)
+		}
+		if st, _ := os.Stat(fileName); st.Mode().Perm() != 0600 {
+			t.Errorf("%s: mode %o", name, st.Mode().Perm())
+		}
+		got, err := ReadConfig(fileName)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		got.Inventory, got.limiter = nil, nil
+		if !reflect.DeepEqual(got, q) {
+			b, _ := os.ReadFile(fileName)
+			t.Errorf("%s read back\n got %+v\nwant %+v\n%s", name, got, q, b)
+		}
+	}
+}
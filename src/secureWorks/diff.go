@@ -0,0 +1,158 @@
+package secureWorks
+
+import "fmt"
+import "reflect"
+import "sort"
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+/* TicketDiff is what changed between two versions of a ticket */
+type TicketDiff struct {
+	TicketId        string        `json:"ticketId"`
+	FromVersion     string        `json:"fromVersion"`
+	ToVersion       string        `json:"toVersion"`
+	Changes         []FieldChange `json:"changes"`
+	NewWorkLogs     []WorkLog     `json:"newWorkLogs"`
+	RemovedWorkLogs []WorkLog     `json:"removedWorkLogs,omitempty"`
+}
+
+func (d TicketDiff) Empty() bool {
+	return len(d.Changes) == 0 && len(d.NewWorkLogs) == 0 && len(d.RemovedWorkLogs) == 0
+}
+
+/*
+ * DiffTickets compares every Ticket field of from and to, in declaration
+ * order, and lists worklog entries present in only one of them.
+ * TicketVersion itself is reported as FromVersion/ToVersion. Fields not
+ * from the API (xml:"-", such as the inventory's DeviceAlias/DeviceIp)
+ * are skipped, so enriching a ticket is not a change.
+ */
+func DiffTickets(from Ticket, to Ticket) TicketDiff {
+	d := TicketDiff{TicketId: to.TicketId, FromVersion: from.TicketVersion, ToVersion: to.TicketVersion}
+	if len(d.TicketId) == 0 {
+		d.TicketId = from.TicketId
+	}
+	fv := reflect.ValueOf(from)
+	tv := reflect.ValueOf(to)
+	for i := 0; i < fv.NumField(); i++ {
+		f := fv.Type().Field(i)
+		name := f.Name
+		if name == "WorkLogs" || name == "TicketVersion" || f.Tag.Get("xml") == "-" {
+			continue
+		}
+		a, b := fieldString(fv.Field(i)), fieldString(tv.Field(i))
+		if a != b {
+			d.Changes = append(d.Changes, FieldChange{Field: name, From: a, To: b})
+		}
+	}
+
+	key := func(w WorkLog) string {
+		return fmt.Sprintf("%d\x00%s\x00%s", w.DateCreated, w.Type, w.Description)
+	}
+	seen := map[string]int{}
+	for _, w := range from.WorkLogs {
+		seen[key(w)]++
+	}
+	for _, w := range to.WorkLogs {
+		if seen[key(w)] > 0 {
+			seen[key(w)]--
+			continue
+		}
+		d.NewWorkLogs = append(d.NewWorkLogs, w)
+	}
+	for _, w := range from.WorkLogs {
+		if seen[key(w)] > 0 {
+			seen[key(w)]--
+			d.RemovedWorkLogs = append(d.RemovedWorkLogs, w)
+		}
+	}
+	return d
+}
+
+/* IdName fields read "Name (Id)", as in PrintDetails */
+func fieldString(v reflect.Value) string {
+	if n, ok := v.Interface().(IdName); ok {
+		if n.Id == 0 && len(n.Name) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%s (%d)", n.Name, n.Id)
+	}
+	return fmt.Sprint(v.Interface())
+}
+func (d TicketDiff) Print() {
+	fmt.Printf("Ticket %s: version %s -> %s\n", d.TicketId, d.FromVersion, d.ToVersion)
+	if d.Empty() {
+		fmt.Printf("  no changes\n")
+	}
+	for _, c := range d.Changes {
+		fmt.Printf("  %s: %q -> %q\n", c.Field, c.From, c.To)
+	}
+	for _, w := range d.NewWorkLogs {
+		fmt.Printf("  + WorkLog %d [%s] %s\n", w.DateCreated, w.Type, w.Description)
+	}
+	for _, w := range d.RemovedWorkLogs {
+		fmt.Printf("  - WorkLog %d [%s] %s\n", w.DateCreated, w.Type, w.Description)
+	}
+}
+
+/* Kinds of DeviceChange */
+const (
+	DeviceAdded   = "added"
+	DeviceRemoved = "removed"
+	DeviceRenamed = "renamed"
+	DeviceAliased = "alias"
+	DeviceReIp    = "reip"
+	DeviceMoved   = "moved"
+)
+
+/* DeviceChange is one difference between two device lists */
+type DeviceChange struct {
+	Change     string `json:"change"`
+	DeviceId   int    `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+}
+
+/*
+ * DiffDevices matches the devices of two GetDeviceList results by
+ * DeviceId and reports those added or removed, and for the rest each
+ * change of name, alias, IP or location (a device both renamed and
+ * re-IP'd gives two changes). The result is ordered by DeviceId.
+ */
+func DiffDevices(from []DeviceList, to []DeviceList) []DeviceChange {
+	old := map[int]DeviceList{}
+	for _, d := range from {
+		old[d.DeviceId] = d
+	}
+	var l []DeviceChange
+	seen := map[int]bool{}
+	for _, d := range to {
+		seen[d.DeviceId] = true
+		o, ok := old[d.DeviceId]
+		if !ok {
+			l = append(l, DeviceChange{Change: DeviceAdded, DeviceId: d.DeviceId, DeviceName: d.DeviceName, To: d.DeviceIp})
+			continue
+		}
+		field := func(change string, a string, b string) {
+			if a != b {
+				l = append(l, DeviceChange{Change: change, DeviceId: d.DeviceId, DeviceName: d.DeviceName, From: a, To: b})
+			}
+		}
+		field(DeviceRenamed, o.DeviceName, d.DeviceName)
+		field(DeviceAliased, o.DeviceAlias, d.DeviceAlias)
+		field(DeviceReIp, o.DeviceIp, d.DeviceIp)
+		field(DeviceMoved, fieldString(reflect.ValueOf(o.Location)), fieldString(reflect.ValueOf(d.Location)))
+	}
+	for _, o := range from {
+		if !seen[o.DeviceId] {
+			l = append(l, DeviceChange{Change: DeviceRemoved, DeviceId: o.DeviceId, DeviceName: o.DeviceName, From: o.DeviceIp})
+		}
+	}
+	sort.SliceStable(l, func(i, j int) bool { return l[i].DeviceId < l[j].DeviceId })
+	return l
+}
@@ -0,0 +1,77 @@
+package secureWorks
+
+import "reflect"
+import "testing"
+
+func TestDiffTickets(t *testing.T) {
+	w1 := WorkLog{DateCreated: 1, Type: "NOTE", Description: "opened"}
+	w2 := WorkLog{DateCreated: 2, Type: "NOTE", Description: "escalated"}
+	from := Ticket{TicketId: "INC-1", TicketVersion: "3", Status: "OPEN", Severity: "LOW",
+		Client: IdName{Id: 7, Name: "Acme"}, WorkLogs: []WorkLog{w1, w1, w2}}
+	to := Ticket{TicketId: "INC-1", TicketVersion: "4", Status: "CLOSED", Severity: "LOW", DateClosed: 99,
+		Client: IdName{Id: 8, Name: "Beta"}, Contact: IdName{Id: 1, Name: "Jo"}, WorkLogs: []WorkLog{w1, w2, w2}}
+
+	d := DiffTickets(from, to)
+	want := TicketDiff{TicketId: "INC-1", FromVersion: "3", ToVersion: "4",
+		Changes: []FieldChange{
+			{Field: "Client", From: "Acme (7)", To: "Beta (8)"},
+			{Field: "Contact", From: "", To: "Jo (1)"},
+			{Field: "DateClosed", From: "0", To: "99"},
+			{Field: "Status", From: "OPEN", To: "CLOSED"},
+		},
+		/* duplicates are counted, not collapsed */
+		NewWorkLogs:     []WorkLog{w2},
+		RemovedWorkLogs: []WorkLog{w1},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("DiffTickets\n got %+v\nwant %+v", d, want)
+	}
+	if d.Empty() {
+		t.Error("Empty() on a diff with changes")
+	}
+
+	same := DiffTickets(from, from)
+	if !same.Empty() || same.TicketId != "INC-1" {
+		t.Errorf("DiffTickets of a ticket with itself: %+v", same)
+	}
+	/* enrichment from the device inventory is not a change */
+	enriched := from
+	enriched.DeviceAlias, enriched.DeviceIp = "fw1", "10.0.0.1"
+	if d := DiffTickets(from, enriched); !d.Empty() {
+		t.Errorf("DiffTickets reports enrichment: %+v", d.Changes)
+	}
+	if d := DiffTickets(from, Ticket{}); d.TicketId != "INC-1" {
+		t.Errorf("TicketId not taken from the older version: %q", d.TicketId)
+	}
+}
+func TestDiffDevices(t *testing.T) {
+	loc := IdName{Id: 1, Name: "HQ"}
+	from := []DeviceList{
+		{DeviceId: 3, DeviceName: "fw3", DeviceIp: "10.0.0.3", Location: loc},
+		{DeviceId: 1, DeviceName: "fw1", DeviceAlias: "edge", DeviceIp: "10.0.0.1", Location: loc},
+		{DeviceId: 2, DeviceName: "fw2", DeviceIp: "10.0.0.2", Location: loc},
+	}
+	to := []DeviceList{
+		{DeviceId: 4, DeviceName: "fw4", DeviceIp: "10.0.0.4"},
+		/* renamed and re-IP'd: two changes */
+		{DeviceId: 1, DeviceName: "fw1b", DeviceAlias: "edge", DeviceIp: "10.0.1.1", Location: loc},
+		{DeviceId: 3, DeviceName: "fw3", DeviceAlias: "dmz", DeviceIp: "10.0.0.3", Location: IdName{Id: 2, Name: "DC"}},
+	}
+	want := []DeviceChange{
+		{Change: DeviceRenamed, DeviceId: 1, DeviceName: "fw1b", From: "fw1", To: "fw1b"},
+		{Change: DeviceReIp, DeviceId: 1, DeviceName: "fw1b", From: "10.0.0.1", To: "10.0.1.1"},
+		{Change: DeviceRemoved, DeviceId: 2, DeviceName: "fw2", From: "10.0.0.2"},
+		{Change: DeviceAliased, DeviceId: 3, DeviceName: "fw3", From: "", To: "dmz"},
+		{Change: DeviceMoved, DeviceId: 3, DeviceName: "fw3", From: "HQ (1)", To: "DC (2)"},
+		{Change: DeviceAdded, DeviceId: 4, DeviceName: "fw4", To: "10.0.0.4"},
+	}
+	if got := DiffDevices(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffDevices\n got %+v\nwant %+v", got, want)
+	}
+	if got := DiffDevices(from, from); got != nil {
+		t.Errorf("DiffDevices of a list with itself: %+v", got)
+	}
+	if got := DiffDevices(nil, from[:1]); len(got) != 1 || got[0].Change != DeviceAdded {
+		t.Errorf("DiffDevices from nothing: %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package digest
+
+import "bytes"
+import html "html/template"
+import "secureWorks"
+import "secureWorks/siem"
+import "sort"
+import "strings"
+import text "text/template"
+import "time"
+
+/* Group is the tickets of one client at one severity */
+type Group struct {
+	Client   string
+	Severity string
+	Tickets  []secureWorks.Ticket
+}
+
+/* Client is a client's groups, most severe first */
+type Client struct {
+	Name   string
+	Total  int
+	Groups []Group
+}
+
+/* Digest is what the templates are executed with */
+type Digest struct {
+	From      time.Time
+	To        time.Time
+	Total     int
+	New       int
+	Clients   []Client
+	Generated time.Time
+}
+
+/*
+ * Build keeps the tickets created or modified in [from, to) and groups
+ * them by client (by name) and severity (CRITICAL first). Tickets within
+ * a group are newest first.
+ */
+func Build(tickets []secureWorks.Ticket, from time.Time, to time.Time) Digest {
+	d := Digest{From: from, To: to, Generated: time.Now()}
+	in := func(v int64) bool {
+		t := secureWorks.TicketTime(v)
+		return !t.IsZero() && !t.Before(from) && t.Before(to)
+	}
+	byClient := map[string]map[string][]secureWorks.Ticket{}
+	for _, t := range tickets {
+		if !in(t.DateCreated) && !in(t.DateModified) {
+			continue
+		}
+		d.Total++
+		if in(t.DateCreated) {
+			d.New++
+		}
+		c := t.Client.Name
+		if len(c) == 0 {
+			c = "(no client)"
+		}
+		if byClient[c] == nil {
+			byClient[c] = map[string][]secureWorks.Ticket{}
+		}
+		sev := strings.ToUpper(t.Severity)
+		byClient[c][sev] = append(byClient[c][sev], t)
+	}
+	for name, sevs := range byClient {
+		c := Client{Name: name}
+		for sev, l := range sevs {
+			sort.Slice(l, func(i, j int) bool { return l[i].DateModified > l[j].DateModified })
+			c.Groups = append(c.Groups, Group{Client: name, Severity: sev, Tickets: l})
+			c.Total += len(l)
+		}
+		sort.Slice(c.Groups, func(i, j int) bool {
+			a, b := siem.Severity(c.Groups[i].Severity), siem.Severity(c.Groups[j].Severity)
+			if a != b {
+				return a > b
+			}
+			return c.Groups[i].Severity < c.Groups[j].Severity
+		})
+		d.Clients = append(d.Clients, c)
+	}
+	sort.Slice(d.Clients, func(i, j int) bool { return d.Clients[i].Name < d.Clients[j].Name })
+	return d
+}
+
+/* Template helpers: {{time .DateCreated}}, {{date .From}} */
+var funcs = map[string]interface{}{
+	"time": func(v int64) string {
+		t := secureWorks.TicketTime(v)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04 MST")
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04 MST")
+	},
+}
+
+func ParseText(name string, s string) (*text.Template, error) {
+	return text.New(name).Funcs(text.FuncMap(funcs)).Parse(s)
+}
+func ParseHTML(name string, s string) (*html.Template, error) {
+	return html.New(name).Funcs(html.FuncMap(funcs)).Parse(s)
+}
+
+/* Render executes the text and (if non-nil) HTML templates with d */
+func (d Digest) Render(t *text.Template, h *html.Template) (string, string, error) {
+	var tb, hb bytes.Buffer
+	if err := t.Execute(&tb, d); err != nil {
+		return "", "", err
+	}
+	if h != nil {
+		if err := h.Execute(&hb, d); err != nil {
+			return "", "", err
+		}
+	}
+	return tb.String(), hb.String(), nil
+}
+
+const DefaultText = `SecureWorks tickets {{date .From}} - {{date .To}}
+{{.Total}} tickets, {{.New}} new
+{{range .Clients}}
+== {{.Name}} ({{.Total}})
+{{range .Groups}}
+  {{.Severity}} ({{len .Tickets}})
+{{range .Tickets}}    {{.TicketId}}  {{.Status}}  {{time .DateModified}}  {{.Devices.Name}}{{with .DeviceIp}} ({{.}}){{end}}  {{.SymptomDescription}}
+{{end}}{{end}}{{else}}
+No tickets.
+{{end}}`
+
+const DefaultHTML = `<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2>SecureWorks tickets {{date .From}} &ndash; {{date .To}}</h2>
+<p>{{.Total}} tickets, {{.New}} new</p>
+{{range .Clients}}
+<h3>{{.Name}} ({{.Total}})</h3>
+{{range .Groups}}
+<h4>{{.Severity}} ({{len .Tickets}})</h4>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Ticket</th><th>Status</th><th>Modified</th><th>Devices</th><th>Symptom</th></tr>
+{{range .Tickets}}<tr><td>{{.TicketId}}</td><td>{{.Status}}</td><td>{{time .DateModified}}</td><td>{{.Devices.Name}}{{with .DeviceIp}} ({{.}}){{end}}</td><td>{{.SymptomDescription}}</td></tr>
+{{end}}</table>
+{{end}}{{else}}
+<p>No tickets.</p>
+{{end}}
+</body></html>
+`
@@ -0,0 +1,134 @@
+package digest
+
+import "secureWorks"
+import "strings"
+import "testing"
+import "time"
+
+var from = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+/* at is n hours after from, in API milliseconds */
+func at(n int) int64 {
+	return from.Add(time.Duration(n) * time.Hour).UnixMilli()
+}
+
+/* layout is "client: SEVERITY id id; SEVERITY id | client: ..." */
+func layout(d Digest) string {
+	var cl []string
+	for _, c := range d.Clients {
+		var gl []string
+		for _, g := range c.Groups {
+			s := g.Severity
+			for _, t := range g.Tickets {
+				s += " " + t.TicketId
+			}
+			gl = append(gl, s)
+		}
+		cl = append(cl, c.Name+": "+strings.Join(gl, "; "))
+	}
+	return strings.Join(cl, " | ")
+}
+func TestBuild(t *testing.T) {
+	acme := secureWorks.IdName{Name: "Acme"}
+	beta := secureWorks.IdName{Name: "Beta"}
+	for _, c := range []struct {
+		name    string
+		tickets []secureWorks.Ticket
+		total   int
+		new     int
+		want    string
+	}{
+		{"empty", nil, 0, 0, ""},
+		{"window", []secureWorks.Ticket{
+			{TicketId: "old", Client: acme, Severity: "HIGH", DateCreated: at(-48), DateModified: at(-30)},
+			{TicketId: "touched", Client: acme, Severity: "HIGH", DateCreated: at(-48), DateModified: at(2)},
+			{TicketId: "new", Client: acme, Severity: "HIGH", DateCreated: at(1), DateModified: at(1)},
+			/* the window is [from, to) */
+			{TicketId: "start", Client: acme, Severity: "HIGH", DateCreated: at(0)},
+			{TicketId: "end", Client: acme, Severity: "HIGH", DateCreated: at(24), DateModified: at(24)},
+			{TicketId: "undated", Client: acme, Severity: "HIGH"},
+		}, 3, 2, "Acme: HIGH touched new start"},
+		{"grouping", []secureWorks.Ticket{
+			{TicketId: "b-low", Client: beta, Severity: "low", DateCreated: at(1), DateModified: at(1)},
+			{TicketId: "a-med", Client: acme, Severity: "Medium", DateCreated: at(1), DateModified: at(3)},
+			{TicketId: "a-crit", Client: acme, Severity: "CRITICAL", DateCreated: at(1), DateModified: at(2)},
+			{TicketId: "a-odd", Client: acme, Severity: "URGENT", DateCreated: at(1), DateModified: at(2)},
+			{TicketId: "a-med2", Client: acme, Severity: "MEDIUM", DateCreated: at(1), DateModified: at(5)},
+			{TicketId: "none", Severity: "HIGH", DateCreated: at(1), DateModified: at(1)},
+		}, 6, 6, "(no client): HIGH none | Acme: CRITICAL a-crit; MEDIUM a-med2 a-med; URGENT a-odd | Beta: LOW b-low"},
+		/* timestamps in seconds count too */
+		{"seconds", []secureWorks.Ticket{
+			{TicketId: "s", Client: beta, Severity: "HIGH", DateCreated: at(-48) / 1000, DateModified: at(6) / 1000},
+		}, 1, 0, "Beta: HIGH s"},
+	} {
+		d := Build(c.tickets, from, from.Add(24*time.Hour))
+		if got := layout(d); got != c.want || d.Total != c.total || d.New != c.new {
+			t.Errorf("%s\n got %s (%d, %d new)\nwant %s (%d, %d new)", c.name, got, d.Total, d.New, c.want, c.total, c.new)
+		}
+		for _, cl := range d.Clients {
+			n := 0
+			for _, g := range cl.Groups {
+				n += len(g.Tickets)
+			}
+			if cl.Total != n {
+				t.Errorf("%s: %s Total %d, groups hold %d", c.name, cl.Name, cl.Total, n)
+			}
+		}
+	}
+}
+func TestRender(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	text, err := ParseText("text", DefaultText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := ParseHTML("html", DefaultHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := []secureWorks.Ticket{{TicketId: "INC-1", Client: secureWorks.IdName{Name: "Acme & Co"}, Severity: "high",
+		Status: "OPEN", DateCreated: at(1), DateModified: at(2), Devices: secureWorks.IdName{Name: "fw1"},
+		DeviceIp: "10.0.0.1", SymptomDescription: "<script>beacon</script>"}}
+	for _, c := range []struct {
+		name    string
+		tickets []secureWorks.Ticket
+		text    []string
+		html    []string
+		notHTML string
+	}{
+		{"tickets", one,
+			[]string{"SecureWorks tickets 2024-01-01 00:00 UTC - 2024-01-02 00:00 UTC\n1 tickets, 1 new\n",
+				"== Acme & Co (1)\n", "  HIGH (1)\n",
+				"    INC-1  OPEN  2024-01-01 02:00 UTC  fw1 (10.0.0.1)  <script>beacon</script>\n"},
+			[]string{"<h3>Acme &amp; Co (1)</h3>", "<h4>HIGH (1)</h4>",
+				"<td>INC-1</td><td>OPEN</td><td>2024-01-01 02:00 UTC</td><td>fw1 (10.0.0.1)</td><td>&lt;script&gt;beacon&lt;/script&gt;</td>"},
+			"<script>"},
+		{"empty", nil, []string{"0 tickets, 0 new\n", "No tickets.\n"}, []string{"<p>No tickets.</p>"}, "<h3>"},
+	} {
+		tb, hb, err := Build(c.tickets, from, from.Add(24*time.Hour)).Render(text, html)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, s := range c.text {
+			if !strings.Contains(tb, s) {
+				t.Errorf("%s: text has no %q:\n%s", c.name, s, tb)
+			}
+		}
+		for _, s := range c.html {
+			if !strings.Contains(hb, s) {
+				t.Errorf("%s: html has no %q:\n%s", c.name, s, hb)
+			}
+		}
+		if strings.Contains(hb, c.notHTML) {
+			t.Errorf("%s: html has %q:\n%s", c.name, c.notHTML, hb)
+		}
+	}
+
+	/* without an HTML template only the text is rendered */
+	if tb, hb, err := Build(one, from, from.Add(24*time.Hour)).Render(text, nil); err != nil || len(tb) == 0 || len(hb) != 0 {
+		t.Errorf("Render(text, nil) = %d bytes, %q, %v", len(tb), hb, err)
+	}
+}
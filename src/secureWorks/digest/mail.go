@@ -0,0 +1,145 @@
+package digest
+
+import "bytes"
+import "crypto/rand"
+import "crypto/tls"
+import "encoding/hex"
+import "fmt"
+import "mime"
+import "mime/quotedprintable"
+import "net"
+import "net/smtp"
+import "strings"
+import "time"
+
+/*
+ * Mailer sends through an SMTP server. With TLS the connection is TLS
+ * from the start (port 465); otherwise STARTTLS is used when the server
+ * offers it, and required unless Insecure. Username/Password use AUTH
+ * PLAIN, which net/smtp only allows over TLS or to localhost.
+ */
+type Mailer struct {
+	Addr      string
+	Username  string
+	Password  string
+	From      string
+	To        []string
+	TLS       bool
+	Insecure  bool
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+/* Message builds a multipart/alternative mail with the text and HTML bodies */
+func Message(from string, to []string, subject string, text string, htmlBody string) []byte {
+	var b bytes.Buffer
+	h := func(k string, v string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	h("From", from)
+	h("To", strings.Join(to, ", "))
+	h("Subject", mime.QEncoding.Encode("utf-8", subject))
+	h("Date", time.Now().Format(time.RFC1123Z))
+	h("MIME-Version", "1.0")
+	part := func(ct string, body string) {
+		h("Content-Type", ct+"; charset=utf-8")
+		h("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		w := quotedprintable.NewWriter(&b)
+		w.Write([]byte(body))
+		w.Close()
+		b.WriteString("\r\n")
+	}
+	if len(htmlBody) == 0 {
+		part("text/plain", text)
+		return b.Bytes()
+	}
+	r := make([]byte, 12)
+	rand.Read(r)
+	boundary := "sw-" + hex.EncodeToString(r)
+	h("Content-Type", "multipart/alternative; boundary=\""+boundary+"\"")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	part("text/plain", text)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	part("text/html", htmlBody)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+func (m *Mailer) dial() (*smtp.Client, error) {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := m.TLSConfig
+	if tc == nil {
+		tc = &tls.Config{ServerName: host}
+	}
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	d := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if m.TLS {
+		conn, err = tls.DialWithDialer(d, "tcp", m.Addr, tc)
+	} else {
+		conn, err = d.Dial("tcp", m.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !m.TLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			err = c.StartTLS(tc)
+		} else if !m.Insecure {
+			err = fmt.Errorf("%s does not offer STARTTLS", m.Addr)
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+func (m *Mailer) Send(subject string, text string, htmlBody string) error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if len(m.Username) > 0 {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("%s: %v", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(Message(m.From, m.To, subject, text, htmlBody)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
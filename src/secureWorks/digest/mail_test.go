@@ -0,0 +1,199 @@
+package digest
+
+import "bufio"
+import "encoding/base64"
+import "io"
+import "mime"
+import "mime/multipart"
+import "mime/quotedprintable"
+import "net"
+import "net/mail"
+import "strings"
+import "sync"
+import "testing"
+
+/*
+ * smtpSink is a plaintext SMTP server on 127.0.0.1 that accepts every
+ * message, recording the commands and the DATA of each. It offers AUTH
+ * PLAIN, not STARTTLS, and rejects recipients at reject.example.
+ */
+type smtpSink struct {
+	Addr string
+	mu   sync.Mutex
+	cmds []string
+	data []string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpSink{Addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(l string) {
+		io.WriteString(conn, l+"\r\n")
+	}
+	reply("220 sink ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.cmds = append(s.cmds, line)
+		s.mu.Unlock()
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO":
+			reply("250-sink")
+			reply("250 AUTH PLAIN")
+		case verb == "AUTH":
+			reply("235 ok")
+		case verb == "RCPT" && strings.Contains(line, "@reject.example"):
+			reply("550 no such user")
+		case verb == "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.data = append(s.data, b.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+func (s *smtpSink) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.cmds...)
+}
+func (s *smtpSink) Data() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.data...)
+}
+
+func TestMailerSend(t *testing.T) {
+	s := newSMTPSink(t)
+	m := &Mailer{Addr: s.Addr, Username: "soc", Password: "pw", From: "soc@example.com",
+		To: []string{"a@example.com", "b@example.com"}, Insecure: true}
+	if err := m.Send("Digest: 3 tickets ✓", "plain body", "<p>html body</p>"); err != nil {
+		t.Fatal(err)
+	}
+
+	var auth string
+	var rcpt []string
+	for _, c := range s.Commands() {
+		switch {
+		case strings.HasPrefix(c, "AUTH PLAIN "):
+			b, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(c, "AUTH PLAIN "))
+			auth = string(b)
+		case strings.HasPrefix(c, "MAIL FROM:"):
+			if !strings.HasPrefix(c, "MAIL FROM:<soc@example.com>") {
+				t.Errorf("%q", c)
+			}
+		case strings.HasPrefix(c, "RCPT TO:"):
+			rcpt = append(rcpt, c)
+		}
+	}
+	if auth != "\x00soc\x00pw" {
+		t.Errorf("AUTH PLAIN %q", auth)
+	}
+	if strings.Join(rcpt, ",") != "RCPT TO:<a@example.com>,RCPT TO:<b@example.com>" {
+		t.Errorf("recipients %q", rcpt)
+	}
+
+	data := s.Data()
+	if len(data) != 1 {
+		t.Fatalf("%d messages", len(data))
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(data[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Digest: 3 tickets ✓" || msg.Header.Get("To") != "a@example.com, b@example.com" {
+		t.Errorf("headers %v", msg.Header)
+	}
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("Content-Type %q: %v", msg.Header.Get("Content-Type"), err)
+	}
+	var parts []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(quotedprintable.NewReader(p))
+		parts = append(parts, p.Header.Get("Content-Type")+": "+string(b))
+	}
+	want := "text/plain; charset=utf-8: plain body|text/html; charset=utf-8: <p>html body</p>"
+	if strings.Join(parts, "|") != want {
+		t.Errorf("parts\n got %q\nwant %q", parts, want)
+	}
+}
+func TestMailerErrors(t *testing.T) {
+	s := newSMTPSink(t)
+	for _, c := range []struct {
+		name string
+		m    Mailer
+		want string
+	}{
+		{"no recipients", Mailer{Addr: s.Addr, Insecure: true}, "no recipients"},
+		{"STARTTLS required", Mailer{Addr: s.Addr, To: []string{"a@example.com"}}, "does not offer STARTTLS"},
+		{"rejected", Mailer{Addr: s.Addr, To: []string{"a@example.com", "x@reject.example"}, Insecure: true},
+			"x@reject.example: 550"},
+	} {
+		if err := c.m.Send("s", "t", ""); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+	if len(s.Data()) != 0 {
+		t.Errorf("sent %q", s.Data())
+	}
+}
+func TestMessageTextOnly(t *testing.T) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(Message("a@x", []string{"b@x"}, "s", "café = ok", ""))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if msg.Header.Get("Content-Type") != "text/plain; charset=utf-8" || string(b) != "café = ok\r\n" {
+		t.Errorf("%v %q", msg.Header, b)
+	}
+}
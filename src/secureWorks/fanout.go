@@ -0,0 +1,228 @@
+package secureWorks
+
+import "fmt"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+
+/*
+ * Fan-out runs an operation once per customer for accounts (MSSPs) that
+ * see several clients in GetCustomerList. Each tenant gets a copy of the
+ * Query with its ClientId/LocationId; at most Parallel run at once, and a
+ * failing tenant is recorded in a *FanOutError while the others finish,
+ * so callers get partial results instead of nothing.
+ */
+type Tenant struct {
+	ClientId   string `json:"clientId"`
+	LocationId string `json:"locationId"`
+	Name       string `json:"name"`
+}
+type TenantError struct {
+	Tenant Tenant
+	Err    error
+}
+
+func (e TenantError) Error() string {
+	return fmt.Sprintf("%s (%s/%s): %v", e.Tenant.Name, e.Tenant.ClientId, e.Tenant.LocationId, e.Err)
+}
+
+/* FanOutError lists the tenants that failed; the rest succeeded */
+type FanOutError struct {
+	Failed []TenantError
+	Total  int
+}
+
+func (e *FanOutError) Error() string {
+	l := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		l[i] = f.Error()
+	}
+	return fmt.Sprintf("%d of %d tenants failed: %s", len(e.Failed), e.Total, strings.Join(l, "; "))
+}
+
+/*
+ * Tenants lists the customers from GetCustomerList (only those with the
+ * ids or names in clients, when given), one tenant per location. The
+ * API has no call listing a customer's locations; they are only exposed
+ * through its devices, so without locations each customer's are the
+ * distinct Location ids in its GetDeviceList, fetched parallel at a time.
+ * A customer whose list fails or has no devices gets q.LocationId, and
+ * the failures are returned as a *FanOutError with the full list.
+ * Given locations, every customer gets those.
+ */
+func Tenants(q Query, locations []string, clients []string, parallel int) ([]Tenant, error) {
+	x, err := GetCustomerList(q)
+	if err != nil {
+		return nil, err
+	}
+	var customers []Tenant
+	for _, c := range x.ClientInfo {
+		id := strconv.Itoa(c.Id)
+		if len(clients) > 0 && !matchClient(clients, id, c.Name) {
+			continue
+		}
+		customers = append(customers, Tenant{ClientId: id, Name: c.Name})
+	}
+	found := make([][]string, len(customers))
+	if len(locations) == 0 {
+		err = FanOut(q, customers, parallel, func(q Query, i int) error {
+			d, err := GetDeviceList(q)
+			if err != nil {
+				return err
+			}
+			seen := map[int]bool{}
+			for _, v := range d.Devices {
+				if v.Location.Id != 0 && !seen[v.Location.Id] {
+					seen[v.Location.Id] = true
+					found[i] = append(found[i], strconv.Itoa(v.Location.Id))
+				}
+			}
+			sort.Slice(found[i], func(a, b int) bool { return idLess(found[i][a], found[i][b]) })
+			return nil
+		})
+	}
+	var l []Tenant
+	for i, c := range customers {
+		locs := locations
+		if len(locs) == 0 {
+			locs = found[i]
+		}
+		if len(locs) == 0 {
+			locs = []string{q.LocationId}
+		}
+		for _, loc := range locs {
+			c.LocationId = loc
+			l = append(l, c)
+		}
+	}
+	return l, err
+}
+func matchClient(clients []string, id string, name string) bool {
+	for _, c := range clients {
+		if c == id || strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * FanOut calls fn(tenant's query, index into tenants) for every tenant
+ * with parallel workers (1 if < 1). Once q's context is done, tenants not
+ * yet started fail with its error.
+ */
+func FanOut(q Query, tenants []Tenant, parallel int, fn func(q Query, i int) error) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	ctx := q.context()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	e := &FanOutError{Total: len(tenants)}
+	fail := func(t Tenant, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		e.Failed = append(e.Failed, TenantError{Tenant: t, Err: q.redactError(err)})
+	}
+	jobs := make(chan int)
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				t := tenants[i]
+				if err := ctx.Err(); err != nil {
+					fail(t, err)
+					continue
+				}
+				tq := q
+				tq.ClientId, tq.LocationId = t.ClientId, t.LocationId
+				if err := fn(tq, i); err != nil {
+					q.logger().Warn("tenant failed", "client", t.ClientId, "location", t.LocationId, "error", q.redactError(err))
+					fail(t, err)
+				}
+			}
+		}()
+	}
+	for i := range tenants {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	sort.Slice(e.Failed, func(i, j int) bool { return tenantLess(e.Failed[i].Tenant, e.Failed[j].Tenant) })
+	return e
+}
+func tenantLess(a Tenant, b Tenant) bool {
+	if a.ClientId != b.ClientId {
+		return idLess(a.ClientId, b.ClientId)
+	}
+	return idLess(a.LocationId, b.LocationId)
+}
+
+/* idLess orders numeric ids by value, anything else as text */
+func idLess(a string, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
+
+/* Merged results, each row tagged with the tenant it came from */
+type TenantDevice struct {
+	Tenant Tenant
+	Device DeviceList
+}
+type TenantContact struct {
+	Tenant  Tenant
+	Contact IdName
+}
+
+/*
+ * The FanOut* helpers return the rows of the tenants that succeeded, in
+ * tenant order, with FanOut's error. Each worker only writes its own
+ * tenant's slot, so no lock is needed. There are none for the queue:
+ * getQueueCount and getQueueTicketIds take no clientId or locationId,
+ * so every tenant would get the account's whole queue; call them once.
+ */
+func FanOutDevices(q Query, tenants []Tenant, parallel int) ([]TenantDevice, error) {
+	r := make([][]TenantDevice, len(tenants))
+	err := FanOut(q, tenants, parallel, func(q Query, i int) error {
+		x, err := GetDeviceList(q)
+		if err != nil {
+			return err
+		}
+		for _, d := range x.Devices {
+			r[i] = append(r[i], TenantDevice{tenants[i], d})
+		}
+		return nil
+	})
+	var l []TenantDevice
+	for _, v := range r {
+		l = append(l, v...)
+	}
+	return l, err
+}
+func FanOutContacts(q Query, tenants []Tenant, parallel int) ([]TenantContact, error) {
+	r := make([][]TenantContact, len(tenants))
+	err := FanOut(q, tenants, parallel, func(q Query, i int) error {
+		x, err := GetContactList(q)
+		if err != nil {
+			return err
+		}
+		for _, c := range x.Contacts {
+			r[i] = append(r[i], TenantContact{tenants[i], c})
+		}
+		return nil
+	})
+	var l []TenantContact
+	for _, v := range r {
+		l = append(l, v...)
+	}
+	return l, err
+}
@@ -0,0 +1,141 @@
+package secureWorks
+
+import "context"
+import "errors"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "reflect"
+import "regexp"
+import "sync/atomic"
+import "testing"
+
+/*
+ * tenantServer answers getCustomerList with clients 7 Acme, 8 Beta and 9
+ * Gamma, and the per-client calls with rows named after the clientId and
+ * locationId asked for. Client 8 always faults; client 9 has no devices.
+ */
+func tenantServer(t *testing.T, calls *int32) *httptest.Server {
+	opRe := regexp.MustCompile(`<ser:(\w+)>`)
+	clientRe := regexp.MustCompile(`<clientId>([^<]*)</clientId>`)
+	locationRe := regexp.MustCompile(`<locationId>([^<]*)</locationId>`)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		op := string(opRe.FindSubmatch(b)[1])
+		var client, location string
+		if m := clientRe.FindSubmatch(b); m != nil {
+			client, location = string(m[1]), string(locationRe.FindSubmatch(b)[1])
+		}
+		if op != "getCustomerList" {
+			atomic.AddInt32(calls, 1)
+		}
+		body := ""
+		switch {
+		case op == "getCustomerList":
+			body = `<getCustomerListResponse><clientInfo><id>7</id><name>Acme</name></clientInfo>` +
+				`<clientInfo><id>8</id><name>Beta</name></clientInfo><clientInfo><id>9</id><name>Gamma</name></clientInfo></getCustomerListResponse>`
+		case client == "8":
+			io.WriteString(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Server</faultcode><faultstring>down</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		case op == "getDeviceList" && client == "7" && location == "":
+			/* the devices of every location, which is how Tenants finds them */
+			for _, loc := range []string{"5", "3", "5"} {
+				body += `<device><deviceName>fw</deviceName><location><id>` + loc + `</id></location></device>`
+			}
+			body = `<getDeviceListResponse>` + body + `</getDeviceListResponse>`
+		case op == "getDeviceList" && client == "7":
+			body = `<getDeviceListResponse><device><deviceName>fw-7-` + location + `</deviceName></device></getDeviceListResponse>`
+		case op == "getDeviceList":
+			body = `<getDeviceListResponse></getDeviceListResponse>`
+		case op == "getContacts":
+			body = `<getContactsResponse><getContactList><id>1</id><name>soc-` + client + `-` + location +
+				`</name></getContactList></getContactsResponse>`
+		}
+		io.WriteString(w, `<Envelope><Body>`+body+`</Body></Envelope>`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+func failedTenants(err error) []Tenant {
+	var f *FanOutError
+	if !errors.As(err, &f) {
+		return nil
+	}
+	var l []Tenant
+	for _, e := range f.Failed {
+		l = append(l, e.Tenant)
+	}
+	return l
+}
+func TestTenants(t *testing.T) {
+	var calls int32
+	s := tenantServer(t, &calls)
+	q := Query{ApiUri: s.URL, ClientId: "1", LocationId: "1"}
+
+	/* locations from each customer's devices; q.LocationId for the rest */
+	l, err := Tenants(q, nil, nil, 2)
+	want := []Tenant{{"7", "3", "Acme"}, {"7", "5", "Acme"}, {"8", "1", "Beta"}, {"9", "1", "Gamma"}}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("Tenants\n got %+v\nwant %+v", l, want)
+	}
+	if f := failedTenants(err); !reflect.DeepEqual(f, []Tenant{{ClientId: "8", Name: "Beta"}}) {
+		t.Errorf("Tenants error %v", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	l, err = Tenants(q, []string{"2", "4"}, []string{"beta", "9"}, 2)
+	want = []Tenant{{"8", "2", "Beta"}, {"8", "4", "Beta"}, {"9", "2", "Gamma"}, {"9", "4", "Gamma"}}
+	if err != nil || !reflect.DeepEqual(l, want) || atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("Tenants with locations\n got %+v, %v, %d calls\nwant %+v", l, err, calls, want)
+	}
+}
+func TestFanOutPerTenant(t *testing.T) {
+	var calls int32
+	s := tenantServer(t, &calls)
+	q := Query{ApiUri: s.URL, ClientId: "1", LocationId: "1"}
+	tenants := []Tenant{{"7", "3", "Acme"}, {"8", "1", "Beta"}, {"7", "5", "Acme"}, {"9", "1", "Gamma"}}
+
+	devices, err := FanOutDevices(q, tenants, 3)
+	var got []string
+	for _, d := range devices {
+		got = append(got, d.Tenant.Name+" "+d.Device.DeviceName)
+	}
+	if !reflect.DeepEqual(got, []string{"Acme fw-7-3", "Acme fw-7-5"}) {
+		t.Errorf("FanOutDevices %q", got)
+	}
+	var f *FanOutError
+	if !errors.As(err, &f) || f.Total != 4 || !reflect.DeepEqual(failedTenants(err), []Tenant{{"8", "1", "Beta"}}) {
+		t.Errorf("FanOutDevices error %v", err)
+	}
+
+	contacts, err := FanOutContacts(q, tenants, 2)
+	got = nil
+	for _, c := range contacts {
+		got = append(got, c.Tenant.ClientId+"/"+c.Tenant.LocationId+" "+c.Contact.Name)
+	}
+	if !reflect.DeepEqual(got, []string{"7/3 soc-7-3", "7/5 soc-7-5", "9/1 soc-9-1"}) || len(failedTenants(err)) != 1 {
+		t.Errorf("FanOutContacts %q, %v", got, err)
+	}
+}
+func TestFanOutCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tenants := []Tenant{{"1", "", "a"}, {"2", "", "b"}, {"10", "", "c"}}
+	var ran []string
+	err := FanOut(Query{}.WithContext(ctx), tenants, 1, func(q Query, i int) error {
+		ran = append(ran, q.ClientId)
+		cancel()
+		return nil
+	})
+	var f *FanOutError
+	if !errors.As(err, &f) || len(ran) != 1 || f.Total != 3 || len(f.Failed) != 2 {
+		t.Fatalf("ran %q, %v", ran, err)
+	}
+	/* failures are ordered by numeric id */
+	for n, id := range []string{"2", "10"} {
+		if e := f.Failed[n]; e.Tenant.ClientId != id || !errors.Is(e.Err, context.Canceled) {
+			t.Errorf("failure %d: %v", n, e)
+		}
+	}
+}
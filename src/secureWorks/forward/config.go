@@ -0,0 +1,257 @@
+package forward
+
+import "crypto/tls"
+import "crypto/x509"
+import "encoding/json"
+import "fmt"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "secureWorks/siem"
+import "secureWorks/watch"
+import "strings"
+import "text/template"
+import "time"
+
+/*
+ * Targets are configured in a JSON file:
+ *
+ *   {"targets": [
+ *     {"type": "webhook", "name": "chatops", "url": "https://...",
+ *      "secretEnv": "CHATOPS_HMAC", "template": "chatops.tmpl",
+ *      "contentType": "application/json", "headers": {"X-Team": "soc"}},
+ *     {"type": "syslog", "name": "qradar", "address": "tls://siem:6514",
+ *      "format": "leef", "facility": "local4", "caFile": "siem-ca.pem",
+ *      "worklogs": true, "stateFile": "qradar-worklogs.json"},
+ *     {"type": "splunk", "url": "https://hec:8088", "secretEnv": "HEC_TOKEN",
+ *      "index": "soc", "flatten": true, "batchSize": 100},
+ *     {"type": "elasticsearch", "url": "https://es:9200", "index": "tickets",
+ *      "username": "export", "secretEnv": "ES_PASSWORD"},
+ *     {"type": "jira", "url": "https://acme.atlassian.net",
+ *      "username": "soc@acme.example", "secretEnv": "JIRA_TOKEN",
+ *      "fields": {"project.key": "SOC", "issuetype.name": "Task",
+ *                 "priority.name": "{{map \"priority\" .Severity}}"},
+ *      "maps": {"priority": {"CRITICAL": "Highest", "HIGH": "High"}}},
+ *     {"type": "servicenow", "url": "https://acme.service-now.com",
+ *      "username": "sync", "secretEnv": "SNOW_PASSWORD"}
+ *   ]}
+ *
+ * Secrets may be given inline as "secret" or read from "secretEnv"; for
+ * splunk it is the HEC token, for elasticsearch the API key (or the
+ * password with "username"); for jira the API token; for servicenow the
+ * password. Issue "fields" are added to (or replace) defaultFields.
+ * Relative template, CA and state file paths are resolved against the
+ * config file.
+ */
+type TargetConfig struct {
+	Type         string                       `json:"type"`
+	Name         string                       `json:"name"`
+	URL          string                       `json:"url"`
+	Secret       string                       `json:"secret"`
+	SecretEnv    string                       `json:"secretEnv"`
+	Template     string                       `json:"template"`
+	ContentType  string                       `json:"contentType"`
+	Headers      map[string]string            `json:"headers"`
+	Address      string                       `json:"address"`
+	Format       string                       `json:"format"`
+	Facility     string                       `json:"facility"`
+	AppName      string                       `json:"appName"`
+	CAFile       string                       `json:"caFile"`
+	WorkLogs     bool                         `json:"worklogs"`
+	StateFile    string                       `json:"stateFile"`
+	Index        string                       `json:"index"`
+	SourceType   string                       `json:"sourceType"`
+	Channel      string                       `json:"channel"`
+	Username     string                       `json:"username"`
+	Flatten      bool                         `json:"flatten"`
+	BatchSize    int                          `json:"batchSize"`
+	Fields       map[string]string            `json:"fields"`
+	Maps         map[string]map[string]string `json:"maps"`
+	Table        string                       `json:"table"`
+	CommentField string                       `json:"commentField"`
+}
+
+/* Issue fields used unless a target's "fields" override them */
+var defaultFields = map[string]map[string]string{
+	"jira": {
+		"summary":     "{{.TicketId}}: {{.SymptomDescription}}",
+		"description": ticketSummary,
+	},
+	"servicenow": {
+		"short_description": "{{.TicketId}}: {{.SymptomDescription}}",
+		"description":       ticketSummary,
+		"correlation_id":    "{{.TicketId}}",
+	},
+}
+
+const ticketSummary = `{{.DetailedDescription}}
+
+SecureWorks ticket {{.TicketId}} (version {{.TicketVersion}})
+Severity: {{.Severity}}
+Status: {{.Status}}
+Client: {{.Client.Name}}
+Devices: {{.Devices.Name}}
+Created: {{time .DateCreated}}`
+
+/* Template helpers: {{json .}}, {{time .DateCreated}} */
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"time": func(v int64) string {
+		return secureWorks.TicketTime(v).Format(time.RFC3339)
+	},
+}
+
+func LoadTargets(fileName string) ([]Target, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var c struct {
+		Targets []TargetConfig `json:"targets"`
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	var l []Target
+	names := map[string]bool{}
+	for i, tc := range c.Targets {
+		if len(tc.Name) == 0 {
+			tc.Name = fmt.Sprintf("%s-%d", tc.Type, i+1)
+		}
+		if names[tc.Name] {
+			return nil, fmt.Errorf("%s: duplicate target name %q", fileName, tc.Name)
+		}
+		names[tc.Name] = true
+		if len(tc.SecretEnv) > 0 {
+			v, ok := os.LookupEnv(tc.SecretEnv)
+			if !ok {
+				return nil, fmt.Errorf("%s: target %s: $%s is not set", fileName, tc.Name, tc.SecretEnv)
+			}
+			tc.Secret = v
+		}
+		t, err := tc.target(filepath.Dir(fileName))
+		if err != nil {
+			return nil, fmt.Errorf("%s: target %s: %v", fileName, tc.Name, err)
+		}
+		l = append(l, t)
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("%s: no targets", fileName)
+	}
+	return l, nil
+}
+func (tc TargetConfig) target(dir string) (Target, error) {
+	switch strings.ToLower(tc.Type) {
+	case "webhook":
+		if len(tc.URL) == 0 {
+			return nil, fmt.Errorf("url is required")
+		}
+		w := &Webhook{TargetName: tc.Name, URL: tc.URL, Secret: tc.Secret,
+			ContentType: tc.ContentType, Headers: tc.Headers}
+		if len(tc.Template) > 0 {
+			p := tc.Template
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			t, err := template.New(filepath.Base(p)).Funcs(templateFuncs).ParseFiles(p)
+			if err != nil {
+				return nil, err
+			}
+			w.Template = t
+		}
+		return w, nil
+	case "syslog":
+		sl, err := siem.NewSyslog(tc.Address)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := siem.Format(tc.Format, secureWorks.Ticket{}, nil); err != nil {
+			return nil, err
+		}
+		if len(tc.Facility) > 0 {
+			if sl.Facility, err = siem.Facility(tc.Facility); err != nil {
+				return nil, err
+			}
+		}
+		sl.AppName = tc.AppName
+		if len(tc.CAFile) > 0 {
+			pem, err := os.ReadFile(resolve(dir, tc.CAFile))
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s: no certificates", tc.CAFile)
+			}
+			sl.TLSConfig = &tls.Config{RootCAs: pool}
+		}
+		s := &SyslogTarget{TargetName: tc.Name, Syslog: sl, Format: tc.Format}
+		if tc.WorkLogs {
+			p := tc.StateFile
+			if len(p) == 0 {
+				p = tc.Name + "-worklogs.json"
+			}
+			if s.Cursor, err = watch.LoadCursor(resolve(dir, p)); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case "splunk":
+		if len(tc.URL) == 0 || len(tc.Secret) == 0 {
+			return nil, fmt.Errorf("url and HEC token (secret) are required")
+		}
+		return &Splunk{TargetName: tc.Name, URL: tc.URL, Token: tc.Secret, Index: tc.Index,
+			SourceType: tc.SourceType, Channel: tc.Channel, Flatten: tc.Flatten, Batch: tc.BatchSize}, nil
+	case "elasticsearch", "elastic":
+		if len(tc.URL) == 0 {
+			return nil, fmt.Errorf("url is required")
+		}
+		e := &Elastic{TargetName: tc.Name, URL: tc.URL, Index: tc.Index, Username: tc.Username,
+			Flatten: tc.Flatten, Batch: tc.BatchSize}
+		if len(tc.Username) > 0 {
+			e.Password = tc.Secret
+		} else {
+			e.APIKey = tc.Secret
+		}
+		return e, nil
+	case "jira", "servicenow":
+		if len(tc.URL) == 0 {
+			return nil, fmt.Errorf("url is required")
+		}
+		kind := strings.ToLower(tc.Type)
+		fields := map[string]string{}
+		for k, v := range defaultFields[kind] {
+			fields[k] = v
+		}
+		for k, v := range tc.Fields {
+			fields[k] = v
+		}
+		m, err := NewMapping(fields, tc.Maps)
+		if err != nil {
+			return nil, err
+		}
+		it := &IssueTarget{TargetName: tc.Name, Mapping: m}
+		if kind == "jira" {
+			it.Tracker = &Jira{Name: tc.Name, URL: tc.URL, Username: tc.Username, Token: tc.Secret}
+		} else {
+			it.Tracker = &ServiceNow{Name: tc.Name, URL: tc.URL, Table: tc.Table,
+				Username: tc.Username, Password: tc.Secret, CommentField: tc.CommentField}
+		}
+		p := tc.StateFile
+		if len(p) == 0 {
+			p = tc.Name + "-links.json"
+		}
+		it.LinkFile = resolve(dir, p)
+		return it, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", tc.Type)
+}
+func resolve(dir string, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
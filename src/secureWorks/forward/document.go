@@ -0,0 +1,80 @@
+package forward
+
+import "crypto/sha1"
+import "encoding/hex"
+import "secureWorks"
+import "strconv"
+import "time"
+
+/*
+ * Document is one indexed record for the Splunk and Elasticsearch
+ * exporters. Id is stable across re-exports: "<ticket>@<version>" for a
+ * ticket, and "<ticket>/worklog/<dateCreated>-<hash>" for a flattened
+ * worklog entry, so the same entry carried by several ticket versions
+ * is still a single document.
+ */
+type Document struct {
+	Id   string
+	Time time.Time
+	Kind string
+	Body interface{}
+}
+
+type ticketDoc struct {
+	Timestamp string `json:"@timestamp"`
+	Kind      string `json:"kind"`
+	secureWorks.Ticket
+}
+type workLogDoc struct {
+	Timestamp     string             `json:"@timestamp"`
+	Kind          string             `json:"kind"`
+	TicketId      string             `json:"TicketId"`
+	TicketVersion string             `json:"TicketVersion"`
+	TicketType    string             `json:"TicketType"`
+	Severity      string             `json:"Severity"`
+	Status        string             `json:"Status"`
+	Client        secureWorks.IdName `json:"Client"`
+	Devices       secureWorks.IdName `json:"Devices"`
+	secureWorks.WorkLog
+}
+
+func docTime(v int64) time.Time {
+	if t := secureWorks.TicketTime(v); !t.IsZero() {
+		return t
+	}
+	return time.Now()
+}
+
+/* workLogKey tells apart worklog entries, including ones created in the same millisecond */
+func workLogKey(w secureWorks.WorkLog) string {
+	h := sha1.Sum([]byte(w.Type + "\x00" + w.Description))
+	return strconv.FormatInt(w.DateCreated, 10) + "-" + hex.EncodeToString(h[:4])
+}
+
+/*
+ * Documents turns t into one document with the worklogs nested, or with
+ * flatten, a ticket document without them followed by one per worklog.
+ */
+func Documents(t secureWorks.Ticket, flatten bool) []Document {
+	ts := docTime(t.DateModified)
+	d := ticketDoc{Timestamp: ts.UTC().Format(time.RFC3339Nano), Kind: "ticket", Ticket: t}
+	if flatten {
+		d.WorkLogs = nil
+	}
+	l := []Document{{Id: t.TicketId + "@" + t.TicketVersion, Time: ts, Kind: "ticket", Body: d}}
+	if !flatten {
+		return l
+	}
+	for _, w := range t.WorkLogs {
+		ts := docTime(w.DateCreated)
+		l = append(l, Document{
+			Id:   t.TicketId + "/worklog/" + workLogKey(w),
+			Time: ts,
+			Kind: "worklog",
+			Body: workLogDoc{Timestamp: ts.UTC().Format(time.RFC3339Nano), Kind: "worklog",
+				TicketId: t.TicketId, TicketVersion: t.TicketVersion, TicketType: t.TicketType,
+				Severity: t.Severity, Status: t.Status, Client: t.Client, Devices: t.Devices, WorkLog: w},
+		})
+	}
+	return l
+}
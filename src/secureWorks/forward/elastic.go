@@ -0,0 +1,129 @@
+package forward
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "secureWorks"
+import "strings"
+import "time"
+
+/*
+ * Elastic indexes tickets through the _bulk API, BatchSize tickets per
+ * request. Documents are written with the "index" action under their
+ * Document id, so exporting a ticket version again overwrites it instead
+ * of adding a duplicate. Authentication is an API key, or basic auth when
+ * Username is set.
+ */
+type Elastic struct {
+	TargetName string
+	URL        string
+	Index      string
+	APIKey     string
+	Username   string
+	Password   string
+	Flatten    bool
+	Batch      int
+	Client     *http.Client
+}
+
+func (e *Elastic) Name() string {
+	return e.TargetName
+}
+func (e *Elastic) BatchSize() int {
+	if e.Batch > 0 {
+		return e.Batch
+	}
+	return 500
+}
+func (e *Elastic) Send(ctx context.Context, t secureWorks.Ticket) error {
+	return e.SendBatch(ctx, []secureWorks.Ticket{t})
+}
+
+/* Render returns the _bulk request body (NDJSON, action line then document) */
+func (e *Elastic) Render(l []secureWorks.Ticket) ([]byte, error) {
+	index := e.Index
+	if len(index) == 0 {
+		index = "secureworks-tickets"
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, t := range l {
+		for _, d := range Documents(t, e.Flatten) {
+			action := map[string]map[string]string{"index": {"_index": index, "_id": d.Id}}
+			if err := enc.Encode(action); err != nil {
+				return nil, err
+			}
+			if err := enc.Encode(d.Body); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+/* _bulk answers 200 even when items fail; those are reported from the body */
+func (e *Elastic) SendBatch(ctx context.Context, l []secureWorks.Ticket) error {
+	body, err := e.Render(l)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(e.URL, "/")+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(e.Username) > 0 {
+		req.SetBasicAuth(e.Username, e.Password)
+	} else if len(e.APIKey) > 0 {
+		req.Header.Set("Authorization", "ApiKey "+e.APIKey)
+	}
+	c := e.Client
+	if c == nil {
+		c = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(b) > 512 {
+			b = b[:512]
+		}
+		return fmt.Errorf("%s: %s: %s", e.TargetName, resp.Status, bytes.TrimSpace(b))
+	}
+	var r struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Id     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("%s: bulk response: %v", e.TargetName, err)
+	}
+	if !r.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range r.Items {
+		for _, v := range item {
+			if v.Status/100 != 2 {
+				if failed == 0 {
+					first = fmt.Sprintf("%s: %d %s", v.Id, v.Status, v.Error)
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("%s: %d of %d bulk items failed, first %s", e.TargetName, failed, len(r.Items), first)
+}
@@ -0,0 +1,76 @@
+package forward
+
+import "bufio"
+import "bytes"
+import "context"
+import "encoding/json"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "secureWorks"
+import "strings"
+import "testing"
+
+func TestElasticSendBatch(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	response := `{"errors": false, "items": []}`
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if strings.HasPrefix(response, "!") {
+			http.Error(w, response[1:], http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, response)
+	}))
+	defer s.Close()
+
+	tk := secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "2",
+		WorkLogs: []secureWorks.WorkLog{{DateCreated: 1700000000000, Type: "NOTE", Description: "opened"}}}
+	e := &Elastic{TargetName: "es", URL: s.URL, APIKey: "key", Flatten: true}
+	if err := e.SendBatch(context.Background(), []secureWorks.Ticket{tk}); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/_bulk" || got.Header.Get("Content-Type") != "application/x-ndjson" ||
+		got.Header.Get("Authorization") != "ApiKey key" {
+		t.Errorf("request %s %v", got.URL.Path, got.Header)
+	}
+
+	/* action and document lines alternate, each action naming the document id */
+	var lines []map[string]interface{}
+	for sc := bufio.NewScanner(bytes.NewReader(body)); sc.Scan(); {
+		var m map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("%s: %v", sc.Bytes(), err)
+		}
+		lines = append(lines, m)
+	}
+	want := Documents(tk, true)
+	if len(lines) != 2*len(want) {
+		t.Fatalf("%d lines, want %d:\n%s", len(lines), 2*len(want), body)
+	}
+	for i, d := range want {
+		action, _ := lines[2*i]["index"].(map[string]interface{})
+		if action["_index"] != "secureworks-tickets" || action["_id"] != d.Id || lines[2*i+1]["kind"] != d.Kind {
+			t.Errorf("document %d\n got %v %v\nwant %s %s", i, lines[2*i], lines[2*i+1], d.Id, d.Kind)
+		}
+	}
+
+	e.Username, e.Password = "export", "pw"
+	response = `{"errors": true, "items": [{"index": {"_id": "INC-1@2", "status": 201}},
+		{"index": {"_id": "INC-1/worklog/x", "status": 400, "error": {"type": "mapper_parsing_exception"}}}]}`
+	err := e.SendBatch(context.Background(), []secureWorks.Ticket{tk})
+	if u, p, ok := got.BasicAuth(); !ok || u != "export" || p != "pw" {
+		t.Errorf("basic auth %q %q %v", u, p, ok)
+	}
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 bulk items failed, first INC-1/worklog/x: 400") ||
+		!strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("item errors: %v", err)
+	}
+
+	response = "!bad credentials"
+	if err := e.Send(context.Background(), tk); err == nil || !strings.Contains(err.Error(), "401 Unauthorized: bad credentials") {
+		t.Errorf("non-2xx: %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+package forward
+
+import "context"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "net/url"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+/* Target delivers one ticket version somewhere: a webhook, a SIEM, ... */
+type Target interface {
+	Name() string
+	Send(ctx context.Context, t secureWorks.Ticket) error
+}
+
+/*
+ * Forwarder polls GetUpdates and hands every new ticket version to each
+ * target through an on-disk outbox, so deliveries survive restarts and
+ * failed ones are retried with backoff. State lives under Dir:
+ *
+ *   <dir>/outbox/<target>/<ticket>@<version>.json   pending deliveries
+ *   <dir>/outbox/<target>/quarantine/                entries that did not
+ *                                                    parse, set aside
+ *   <dir>/status.json                                status per target,
+ *                                                    ticket and version
+ *
+ * Delivered and failed records are pruned Retention after their last
+ * attempt; a ticket version GetUpdates returns again after that is
+ * forwarded again.
+ */
+type Forwarder struct {
+	Dir         string
+	Targets     []Target
+	TicketTypes []string
+	Limit       int
+	/* Deliveries are dropped (status "failed") after this many attempts */
+	MaxAttempts int
+	Retention   time.Duration
+	mu          sync.Mutex
+	status      map[string]*Status
+	dirty       bool
+}
+
+/* Status is the delivery record of one ticket version to one target */
+type Status struct {
+	Target      string    `json:"target"`
+	TicketId    string    `json:"ticketId"`
+	Version     string    `json:"version"`
+	State       string    `json:"state"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+	DeliveredAt time.Time `json:"deliveredAt,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	Failed    = "failed"
+)
+
+type outboxEntry struct {
+	Target string             `json:"target"`
+	Ticket secureWorks.Ticket `json:"ticket"`
+}
+
+func New(dir string, targets ...Target) (*Forwarder, error) {
+	f := &Forwarder{Dir: dir, Targets: targets, MaxAttempts: 20, Retention: 30 * 24 * time.Hour,
+		status: map[string]*Status{}}
+	for _, t := range targets {
+		if err := os.MkdirAll(f.outboxDir(t.Name()), 0700); err != nil {
+			return nil, err
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "status.json"))
+	if err == nil {
+		var l []*Status
+		if err := json.Unmarshal(b, &l); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Join(dir, "status.json"), err)
+		}
+		for _, s := range l {
+			/* Records from before UpdatedAt start their retention now */
+			if s.UpdatedAt.IsZero() {
+				s.UpdatedAt = time.Now()
+			}
+			f.status[statusKey(s.Target, s.TicketId, s.Version)] = s
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return f, nil
+}
+func statusKey(target string, id string, version string) string {
+	return target + "\x00" + id + "\x00" + version
+}
+func (f *Forwarder) outboxDir(target string) string {
+	return filepath.Join(f.Dir, "outbox", url.PathEscape(target))
+}
+func (f *Forwarder) outboxFile(target string, id string, version string) string {
+	return filepath.Join(f.outboxDir(target), url.PathEscape(id)+"@"+url.PathEscape(version)+".json")
+}
+
+/* Statuses returns every delivery record, oldest ticket first */
+func (f *Forwarder) Statuses() []Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var l []Status
+	for _, s := range f.status {
+		l = append(l, *s)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].TicketId != l[j].TicketId {
+			return l[i].TicketId < l[j].TicketId
+		}
+		if l[i].Version != l[j].Version {
+			return l[i].Version < l[j].Version
+		}
+		return l[i].Target < l[j].Target
+	})
+	return l
+}
+
+/* saveStatus writes status.json if a record was added, changed or pruned since the last save */
+func (f *Forwarder) saveStatus() error {
+	f.mu.Lock()
+	dirty := f.dirty
+	f.dirty = false
+	f.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(f.Statuses(), "", "  ")
+	if err == nil {
+		err = writeFile(filepath.Join(f.Dir, "status.json"), b)
+	}
+	if err != nil {
+		f.mu.Lock()
+		f.dirty = true
+		f.mu.Unlock()
+	}
+	return err
+}
+
+/* writeFile replaces fileName through a temporary file, so readers never see it half written */
+func writeFile(fileName string, b []byte) error {
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+/* prune drops finished records last updated before now - Retention */
+func (f *Forwarder) prune(now time.Time) {
+	if f.Retention <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, s := range f.status {
+		if s.State != Pending && s.UpdatedAt.Before(now.Add(-f.Retention)) {
+			delete(f.status, k)
+			f.dirty = true
+		}
+	}
+}
+
+/* Enqueue puts t in every target's outbox unless that version was seen before */
+func (f *Forwarder) Enqueue(t secureWorks.Ticket) error {
+	for _, tg := range f.Targets {
+		key := statusKey(tg.Name(), t.TicketId, t.TicketVersion)
+		f.mu.Lock()
+		_, seen := f.status[key]
+		f.mu.Unlock()
+		if seen {
+			continue
+		}
+		b, err := json.Marshal(outboxEntry{Target: tg.Name(), Ticket: t})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(f.outboxFile(tg.Name(), t.TicketId, t.TicketVersion), b); err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.status[key] = &Status{Target: tg.Name(), TicketId: t.TicketId, Version: t.TicketVersion, State: Pending,
+			UpdatedAt: time.Now()}
+		f.dirty = true
+		f.mu.Unlock()
+	}
+	return f.saveStatus()
+}
+
+/*
+ * BatchTarget is a Target that can take many tickets in one request;
+ * Drain hands it up to BatchSize due deliveries at a time.
+ */
+type BatchTarget interface {
+	Target
+	BatchSize() int
+	SendBatch(ctx context.Context, l []secureWorks.Ticket) error
+}
+
+type delivery struct {
+	file   string
+	ticket secureWorks.Ticket
+	status *Status
+}
+
+/*
+ * Drain attempts every due delivery in the outbox once. A failed
+ * delivery waits 30s, doubling per attempt up to an hour, before the
+ * next try. Outbox entries that do not parse are moved to quarantine
+ * and reported in the returned error once the rest have been attempted.
+ */
+func (f *Forwarder) Drain(ctx context.Context) error {
+	f.prune(time.Now())
+	var quarantined []error
+	for _, tg := range f.Targets {
+		due, bad, err := f.due(tg.Name())
+		quarantined = append(quarantined, bad...)
+		if err != nil {
+			return err
+		}
+		n := 1
+		bt, batch := tg.(BatchTarget)
+		if batch && bt.BatchSize() > 1 {
+			n = bt.BatchSize()
+		}
+		for len(due) > 0 {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			l := due
+			if len(l) > n {
+				l = l[:n]
+			}
+			due = due[len(l):]
+			if batch {
+				tickets := make([]secureWorks.Ticket, len(l))
+				for i, d := range l {
+					tickets[i] = d.ticket
+				}
+				err = bt.SendBatch(ctx, tickets)
+			} else {
+				err = tg.Send(ctx, l[0].ticket)
+			}
+			for _, d := range l {
+				f.record(d, err)
+			}
+			if err := f.saveStatus(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := f.saveStatus(); err != nil {
+		return err
+	}
+	return errors.Join(quarantined...)
+}
+
+/*
+ * due lists the outbox entries of target whose next attempt has come;
+ * bad lists those that did not parse, which are moved to quarantine.
+ */
+func (f *Forwarder) due(target string) (l []delivery, bad []error, err error) {
+	files, err := filepath.Glob(filepath.Join(f.outboxDir(target), "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		var e outboxEntry
+		b, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(b, &e)
+			if err == nil && len(e.Ticket.TicketId) == 0 {
+				err = errors.New("no ticket")
+			}
+			if err != nil {
+				bad = append(bad, f.quarantine(target, file, err))
+				continue
+			}
+		}
+		if err != nil {
+			return nil, bad, fmt.Errorf("%s: %v", file, err)
+		}
+		t := e.Ticket
+		key := statusKey(target, t.TicketId, t.TicketVersion)
+		f.mu.Lock()
+		s := f.status[key]
+		if s == nil {
+			s = &Status{Target: target, TicketId: t.TicketId, Version: t.TicketVersion, State: Pending,
+				UpdatedAt: time.Now()}
+			f.status[key] = s
+			f.dirty = true
+		}
+		ready := !time.Now().Before(s.NextAttempt)
+		f.mu.Unlock()
+		if ready {
+			l = append(l, delivery{file, t, s})
+		}
+	}
+	/* Oldest version of a ticket first: "@9" before "@10" */
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].ticket.TicketId != l[j].ticket.TicketId {
+			return l[i].ticket.TicketId < l[j].ticket.TicketId
+		}
+		return versionLess(l[i].ticket, l[j].ticket)
+	})
+	return l, bad, nil
+}
+
+/* Numeric versions compare as numbers, anything else by DateModified */
+func versionLess(a secureWorks.Ticket, b secureWorks.Ticket) bool {
+	va, ea := strconv.ParseInt(a.TicketVersion, 10, 64)
+	vb, eb := strconv.ParseInt(b.TicketVersion, 10, 64)
+	if ea == nil && eb == nil && va != vb {
+		return va < vb
+	}
+	if a.DateModified != b.DateModified && a.DateModified != 0 && b.DateModified != 0 {
+		return a.DateModified < b.DateModified
+	}
+	return strings.Compare(a.TicketVersion, b.TicketVersion) < 0
+}
+
+/* quarantine moves an unreadable outbox entry aside and returns the error to report */
+func (f *Forwarder) quarantine(target string, file string, cause error) error {
+	dir := filepath.Join(f.outboxDir(target), "quarantine")
+	dest := filepath.Join(dir, filepath.Base(file))
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = os.Rename(file, dest)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v (quarantine failed: %v)", file, cause, err)
+	}
+	return fmt.Errorf("%s: %v, moved to %s", file, cause, dest)
+}
+
+/* record updates the status of d after an attempt and clears finished outbox entries */
+func (f *Forwarder) record(d delivery, err error) {
+	f.mu.Lock()
+	s := d.status
+	s.Attempts++
+	s.UpdatedAt = time.Now()
+	f.dirty = true
+	if err == nil {
+		s.State = Delivered
+		s.LastError = ""
+		s.DeliveredAt = time.Now()
+		s.NextAttempt = time.Time{}
+	} else {
+		s.LastError = err.Error()
+		if s.Attempts >= f.MaxAttempts {
+			s.State = Failed
+		} else {
+			wait := 30 * time.Second << uint(s.Attempts-1)
+			if wait > time.Hour || wait <= 0 {
+				wait = time.Hour
+			}
+			s.NextAttempt = time.Now().Add(wait)
+		}
+	}
+	done := s.State != Pending
+	f.mu.Unlock()
+	if done {
+		os.Remove(d.file)
+	}
+}
+
+/* Poll fetches GetUpdates for each ticket type and enqueues what is new */
+func (f *Forwarder) Poll(q secureWorks.Query) error {
+	types := f.TicketTypes
+	if len(types) == 0 {
+		types = []string{"INCIDENT"}
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	for _, tt := range types {
+		u, err := secureWorks.GetUpdates(q, strings.TrimSpace(tt), "ALL", limit, 0)
+		if err != nil {
+			return err
+		}
+		for _, t := range u.Tickets {
+			if err := f.Enqueue(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/* Run polls and drains every interval until ctx is done */
+func (f *Forwarder) Run(ctx context.Context, q secureWorks.Query, interval time.Duration, errs func(error)) error {
+	q = q.WithContext(ctx)
+	for {
+		if err := f.Poll(q); err != nil {
+			errs(err)
+		}
+		if err := f.Drain(ctx); err != nil && ctx.Err() == nil {
+			errs(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package forward
+
+import "context"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "strings"
+import "testing"
+import "time"
+
+type recordTarget struct {
+	sent []string
+}
+
+func (r *recordTarget) Name() string {
+	return "rec"
+}
+func (r *recordTarget) Send(ctx context.Context, t secureWorks.Ticket) error {
+	r.sent = append(r.sent, t.TicketId+"@"+t.TicketVersion)
+	return nil
+}
+func TestDrainQuarantinesBadEntries(t *testing.T) {
+	dir := t.TempDir()
+	tg := &recordTarget{}
+	f, err := New(dir, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Enqueue(secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(f.outboxDir("rec"), "INC-0@1.json")
+	if err := os.WriteFile(bad, []byte("{truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.Drain(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "INC-0@1.json") {
+		t.Errorf("bad entry not reported: %v", err)
+	}
+	if len(tg.sent) != 1 || tg.sent[0] != "INC-1@2" {
+		t.Errorf("sent %q", tg.sent)
+	}
+	if _, err := os.Stat(filepath.Join(f.outboxDir("rec"), "quarantine", "INC-0@1.json")); err != nil {
+		t.Errorf("not quarantined: %v", err)
+	}
+	if err := f.Drain(context.Background()); err != nil {
+		t.Errorf("second Drain: %v", err)
+	}
+}
+func TestStatusSavedOnlyWhenChanged(t *testing.T) {
+	dir := t.TempDir()
+	f, err := New(dir, &recordTarget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "1"}
+	if err := f.Enqueue(tk); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "status.json")
+	st, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := st.ModTime().Add(-time.Hour)
+	os.Chtimes(file, old, old)
+	if err := f.Enqueue(tk); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := os.Stat(file); !st.ModTime().Equal(old) {
+		t.Error("status.json rewritten for a version already seen")
+	}
+}
+func TestPrune(t *testing.T) {
+	f, err := New(t.TempDir(), &recordTarget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	f.status = map[string]*Status{
+		"old":     {State: Delivered, UpdatedAt: now.Add(-31 * 24 * time.Hour)},
+		"failed":  {State: Failed, UpdatedAt: now.Add(-31 * 24 * time.Hour)},
+		"recent":  {State: Delivered, UpdatedAt: now.Add(-time.Hour)},
+		"pending": {State: Pending, UpdatedAt: now.Add(-31 * 24 * time.Hour)},
+	}
+	f.prune(now)
+	if len(f.status) != 2 || f.status["recent"] == nil || f.status["pending"] == nil || !f.dirty {
+		t.Errorf("after prune: %v dirty %v", f.status, f.dirty)
+	}
+}
+
+type fakeTracker struct {
+	calls []string
+}
+
+func (f *fakeTracker) CreateIssue(ctx context.Context, fields map[string]interface{}) (string, error) {
+	f.calls = append(f.calls, "create "+fields["summary"].(string))
+	return "SOC-1", nil
+}
+func (f *fakeTracker) UpdateIssue(ctx context.Context, key string, fields map[string]interface{}) error {
+	f.calls = append(f.calls, "update "+fields["summary"].(string))
+	return nil
+}
+func (f *fakeTracker) AddComment(ctx context.Context, key string, body string) error {
+	f.calls = append(f.calls, "comment "+body[strings.LastIndex(body, "\n")+1:])
+	return nil
+}
+func TestIssueTargetSkipsOlderVersions(t *testing.T) {
+	m, err := NewMapping(map[string]string{"summary": "{{.TicketVersion}}"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &fakeTracker{}
+	it := &IssueTarget{TargetName: "jira", Tracker: tr, Mapping: m, LinkFile: filepath.Join(t.TempDir(), "links.json")}
+	for _, v := range []string{"2", "10", "9", "10", "11"} {
+		if err := it.Send(context.Background(), secureWorks.Ticket{TicketId: "INC-1", TicketVersion: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"create 2", "update 10", "update 11"}
+	if strings.Join(tr.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("tracker calls %q, want %q", tr.calls, want)
+	}
+}
+func TestDueOrdersVersionsNumerically(t *testing.T) {
+	f, err := New(t.TempDir(), &recordTarget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"10", "9", "100"} {
+		if err := f.Enqueue(secureWorks.Ticket{TicketId: "INC-1", TicketVersion: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, _, err := f.due("rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range l {
+		got = append(got, d.ticket.TicketVersion)
+	}
+	if strings.Join(got, ",") != "9,10,100" {
+		t.Errorf("due order %q", got)
+	}
+}
+func TestIssueTargetSameTimestampWorkLogs(t *testing.T) {
+	m, err := NewMapping(map[string]string{"summary": "{{.TicketVersion}}"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &fakeTracker{}
+	file := filepath.Join(t.TempDir(), "links.json")
+	w := func(at int64, desc string) secureWorks.WorkLog {
+		return secureWorks.WorkLog{DateCreated: at, Type: "NOTE", Description: desc}
+	}
+	send := func(it *IssueTarget, v string, l ...secureWorks.WorkLog) {
+		if err := it.Send(context.Background(), secureWorks.Ticket{TicketId: "INC-1", TicketVersion: v, WorkLogs: l}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	it := &IssueTarget{TargetName: "jira", Tracker: tr, Mapping: m, LinkFile: file}
+	send(it, "1", w(10, "a"), w(20, "b"), w(20, "c"))
+	/* a later version brings another entry at 20, and repeats the others */
+	send(it, "2", w(20, "c"), w(10, "a"), w(20, "d"), w(20, "b"))
+	/* the link file remembers what was posted at 20 */
+	it = &IssueTarget{TargetName: "jira", Tracker: tr, Mapping: m, LinkFile: file}
+	send(it, "2", w(20, "b"), w(20, "d"))
+	send(it, "3", w(20, "d"), w(30, "e"))
+
+	want := []string{"create 1", "comment a", "comment b", "comment c", "update 2", "comment d", "update 3", "comment e"}
+	if strings.Join(tr.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("tracker calls\n got %q\nwant %q", tr.calls, want)
+	}
+}
@@ -0,0 +1,304 @@
+package forward
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "os"
+import "secureWorks"
+import "sort"
+import "strings"
+import "sync"
+import "text/template"
+import "time"
+
+/* Tracker is an issue system that tickets are mirrored into */
+type Tracker interface {
+	CreateIssue(ctx context.Context, fields map[string]interface{}) (key string, err error)
+	UpdateIssue(ctx context.Context, key string, fields map[string]interface{}) error
+	AddComment(ctx context.Context, key string, body string) error
+}
+
+/*
+ * Mapping renders issue fields from a ticket. Each field is a
+ * text/template over the Ticket; dotted names nest ("priority.name"
+ * becomes {"priority": {"name": ...}}). Maps are lookup tables for the
+ * map helper: {{map "priority" .Severity}}.
+ */
+type Mapping struct {
+	Fields map[string]*template.Template
+	Maps   map[string]map[string]string
+}
+
+func NewMapping(fields map[string]string, maps map[string]map[string]string) (*Mapping, error) {
+	m := &Mapping{Fields: map[string]*template.Template{}, Maps: maps}
+	funcs := template.FuncMap{"map": m.lookup}
+	for k, v := range templateFuncs {
+		funcs[k] = v
+	}
+	for name, text := range fields {
+		t, err := template.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		m.Fields[name] = t
+	}
+	return m, nil
+}
+
+/* Unmapped values pass through unchanged */
+func (m *Mapping) lookup(table string, v string) string {
+	if r, ok := m.Maps[table][v]; ok {
+		return r
+	}
+	if r, ok := m.Maps[table][strings.ToUpper(v)]; ok {
+		return r
+	}
+	return v
+}
+func (m *Mapping) Render(t secureWorks.Ticket) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var b bytes.Buffer
+		if err := m.Fields[name].Execute(&b, t); err != nil {
+			return nil, err
+		}
+		obj := out
+		path := strings.Split(name, ".")
+		for _, p := range path[:len(path)-1] {
+			next, ok := obj[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				obj[p] = next
+			}
+			obj = next
+		}
+		obj[path[len(path)-1]] = b.String()
+	}
+	return out, nil
+}
+
+/*
+ * Link ties a ticket to its mirrored issue. Posted holds the keys of the
+ * worklogs copied at LastWorkLog, since several entries can share one
+ * DateCreated and arrive in different ticket versions.
+ */
+type Link struct {
+	TicketId    string    `json:"ticketId"`
+	Key         string    `json:"key"`
+	Version     string    `json:"version"`
+	Modified    int64     `json:"dateModified,omitempty"`
+	LastWorkLog int64     `json:"lastWorkLog"`
+	Posted      []string  `json:"posted,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+/* copied reports whether w was already added as a comment */
+func (k *Link) copied(w secureWorks.WorkLog) bool {
+	if w.DateCreated != k.LastWorkLog {
+		return w.DateCreated < k.LastWorkLog
+	}
+	key := workLogKey(w)
+	for _, p := range k.Posted {
+		if p == key {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * IssueTarget mirrors tickets into a Tracker: the first version of a
+ * ticket creates an issue, newer versions update its fields, and worklog
+ * entries not copied yet are added as comments. The link
+ * table (ticket -> issue key) is a JSON file, saved after every change.
+ *
+ * Changes made in the tracker are not mirrored back. The TicketingService
+ * is read only (getUpdates, getTicketDetail, the queue, device, contact,
+ * customer and attachment lookups); nothing adds a worklog or changes a
+ * ticket, so there is no call to send an issue comment to. Should such an
+ * operation appear (wsdl generates its binding), the link table already
+ * maps issues back to tickets, and comments starting "[SecureWorks " are
+ * the ones copied from worklogs.
+ */
+type IssueTarget struct {
+	TargetName string
+	Tracker    Tracker
+	Mapping    *Mapping
+	LinkFile   string
+	mu         sync.Mutex
+	links      map[string]*Link
+}
+
+func (it *IssueTarget) Name() string {
+	return it.TargetName
+}
+
+/* Links returns the link table, ordered by ticket */
+func (it *IssueTarget) Links() ([]Link, error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if err := it.load(); err != nil {
+		return nil, err
+	}
+	var l []Link
+	for _, k := range it.links {
+		l = append(l, *k)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].TicketId < l[j].TicketId })
+	return l, nil
+}
+func (it *IssueTarget) load() error {
+	if it.links != nil {
+		return nil
+	}
+	it.links = map[string]*Link{}
+	b, err := os.ReadFile(it.LinkFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var l []*Link
+	if err := json.Unmarshal(b, &l); err != nil {
+		it.links = nil
+		return fmt.Errorf("%s: %v", it.LinkFile, err)
+	}
+	for _, k := range l {
+		it.links[k.TicketId] = k
+	}
+	return nil
+}
+func (it *IssueTarget) save() error {
+	var l []*Link
+	for _, k := range it.links {
+		l = append(l, k)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].TicketId < l[j].TicketId })
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := it.LinkFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, it.LinkFile)
+}
+func workLogComment(w secureWorks.WorkLog) string {
+	return fmt.Sprintf("[SecureWorks %s %s]\n%s", w.Type,
+		secureWorks.TicketTime(w.DateCreated).UTC().Format(time.RFC3339), w.Description)
+}
+
+/*
+ * Send is safe to retry: the link is saved as soon as the issue exists,
+ * and after each comment, so a failure part way never creates a second
+ * issue or repeats a comment.
+ */
+func (it *IssueTarget) Send(ctx context.Context, t secureWorks.Ticket) error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if err := it.load(); err != nil {
+		return err
+	}
+	fields, err := it.Mapping.Render(t)
+	if err != nil {
+		return fmt.Errorf("%s: mapping: %v", it.TargetName, err)
+	}
+	k := it.links[t.TicketId]
+	if k == nil {
+		key, err := it.Tracker.CreateIssue(ctx, fields)
+		if err != nil {
+			return err
+		}
+		k = &Link{TicketId: t.TicketId, Key: key, Version: t.TicketVersion, Modified: t.DateModified, Updated: time.Now()}
+		it.links[t.TicketId] = k
+		if err := it.save(); err != nil {
+			return err
+		}
+	} else if versionLess(secureWorks.Ticket{TicketVersion: k.Version, DateModified: k.Modified}, t) {
+		/* An older version delivered late (a retry, say) must not overwrite a newer one */
+		if err := it.Tracker.UpdateIssue(ctx, k.Key, fields); err != nil {
+			return err
+		}
+		k.Version = t.TicketVersion
+		k.Modified = t.DateModified
+		k.Updated = time.Now()
+		if err := it.save(); err != nil {
+			return err
+		}
+	}
+
+	l := append([]secureWorks.WorkLog(nil), t.WorkLogs...)
+	sort.SliceStable(l, func(i, j int) bool { return l[i].DateCreated < l[j].DateCreated })
+	for _, w := range l {
+		if k.copied(w) {
+			continue
+		}
+		if err := it.Tracker.AddComment(ctx, k.Key, workLogComment(w)); err != nil {
+			return err
+		}
+		if w.DateCreated > k.LastWorkLog {
+			k.LastWorkLog = w.DateCreated
+			k.Posted = nil
+		}
+		k.Posted = append(k.Posted, workLogKey(w))
+		k.Updated = time.Now()
+		if err := it.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * doJSON sends body as JSON and decodes a 2xx response into out (when
+ * non-nil); auth sets the credentials on the request.
+ */
+func doJSON(ctx context.Context, c *http.Client, method string, url string, auth func(*http.Request),
+	body interface{}, out interface{}, name string) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	auth(req)
+	if c == nil {
+		c = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(rb) > 512 {
+			rb = rb[:512]
+		}
+		return fmt.Errorf("%s: %s %s: %s: %s", name, method, req.URL.Path, resp.Status, bytes.TrimSpace(rb))
+	}
+	if out == nil || len(rb) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(rb, out); err != nil {
+		return fmt.Errorf("%s: %s %s: %v", name, method, req.URL.Path, err)
+	}
+	return nil
+}
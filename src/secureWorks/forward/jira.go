@@ -0,0 +1,59 @@
+package forward
+
+import "context"
+import "fmt"
+import "net/http"
+import "net/url"
+import "strings"
+
+/*
+ * Jira talks to the Jira REST API v2. Cloud sites authenticate with
+ * Username (the account email) and an API token as Token; Data Center
+ * personal access tokens are sent as a bearer token when Username is
+ * empty. Jira status changes need workflow transitions, so Status is
+ * best mapped to a label or custom field.
+ */
+type Jira struct {
+	Name     string
+	URL      string
+	Username string
+	Token    string
+	Client   *http.Client
+}
+
+func (j *Jira) auth(req *http.Request) {
+	if len(j.Username) > 0 {
+		req.SetBasicAuth(j.Username, j.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.Token)
+	}
+}
+func (j *Jira) url(path string) string {
+	return strings.TrimRight(j.URL, "/") + "/rest/api/2/" + path
+}
+func (j *Jira) CreateIssue(ctx context.Context, fields map[string]interface{}) (string, error) {
+	var r struct {
+		Key string `json:"key"`
+	}
+	err := doJSON(ctx, j.Client, "POST", j.url("issue"), j.auth, map[string]interface{}{"fields": fields}, &r, j.Name)
+	if err == nil && len(r.Key) == 0 {
+		err = fmt.Errorf("%s: create issue: no key in response", j.Name)
+	}
+	return r.Key, err
+}
+
+/* project and issuetype are only settable on create; edits reject them */
+func (j *Jira) UpdateIssue(ctx context.Context, key string, fields map[string]interface{}) error {
+	f := map[string]interface{}{}
+	for k, v := range fields {
+		if k != "project" && k != "issuetype" {
+			f[k] = v
+		}
+	}
+	return doJSON(ctx, j.Client, "PUT", j.url("issue/"+url.PathEscape(key)), j.auth,
+		map[string]interface{}{"fields": f}, nil, j.Name)
+}
+func (j *Jira) AddComment(ctx context.Context, key string, body string) error {
+	return doJSON(ctx, j.Client, "POST", j.url("issue/"+url.PathEscape(key)+"/comment"), j.auth,
+		map[string]string{"body": body}, nil, j.Name)
+}
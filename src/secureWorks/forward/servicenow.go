@@ -0,0 +1,59 @@
+package forward
+
+import "context"
+import "fmt"
+import "net/http"
+import "net/url"
+import "strings"
+
+/*
+ * ServiceNow talks to the Table API (default table "incident"); the
+ * issue key is the record's sys_id. Worklogs are added as work notes, or
+ * as customer-visible comments when CommentField is "comments".
+ */
+type ServiceNow struct {
+	Name         string
+	URL          string
+	Table        string
+	Username     string
+	Password     string
+	CommentField string
+	Client       *http.Client
+}
+
+func (s *ServiceNow) auth(req *http.Request) {
+	req.SetBasicAuth(s.Username, s.Password)
+}
+func (s *ServiceNow) url(sysId string) string {
+	table := s.Table
+	if len(table) == 0 {
+		table = "incident"
+	}
+	u := strings.TrimRight(s.URL, "/") + "/api/now/table/" + url.PathEscape(table)
+	if len(sysId) > 0 {
+		u += "/" + url.PathEscape(sysId)
+	}
+	return u
+}
+func (s *ServiceNow) CreateIssue(ctx context.Context, fields map[string]interface{}) (string, error) {
+	var r struct {
+		Result struct {
+			SysId string `json:"sys_id"`
+		} `json:"result"`
+	}
+	err := doJSON(ctx, s.Client, "POST", s.url(""), s.auth, fields, &r, s.Name)
+	if err == nil && len(r.Result.SysId) == 0 {
+		err = fmt.Errorf("%s: create record: no sys_id in response", s.Name)
+	}
+	return r.Result.SysId, err
+}
+func (s *ServiceNow) UpdateIssue(ctx context.Context, key string, fields map[string]interface{}) error {
+	return doJSON(ctx, s.Client, "PATCH", s.url(key), s.auth, fields, nil, s.Name)
+}
+func (s *ServiceNow) AddComment(ctx context.Context, key string, body string) error {
+	f := s.CommentField
+	if len(f) == 0 {
+		f = "work_notes"
+	}
+	return doJSON(ctx, s.Client, "PATCH", s.url(key), s.auth, map[string]string{f: body}, nil, s.Name)
+}
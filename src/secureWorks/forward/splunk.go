@@ -0,0 +1,102 @@
+package forward
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "net/http"
+import "secureWorks"
+import "strings"
+
+/*
+ * Splunk sends tickets to an HTTP Event Collector, BatchSize tickets per
+ * request to <URL>/services/collector/event. HEC has no document ids, so
+ * each event carries its Document id as the indexed field "doc_id".
+ * Nothing dedups on ingest: a batch sent again (the outbox is at least
+ * once, e.g. after a crash before the entry was removed) is indexed
+ * again, as is every flattened worklog with each new ticket version.
+ * Searches drop those with "| dedup doc_id".
+ */
+type Splunk struct {
+	TargetName string
+	URL        string
+	Token      string
+	Index      string
+	Source     string
+	SourceType string
+	/* X-Splunk-Request-Channel, required when indexer acknowledgement is on */
+	Channel string
+	Flatten bool
+	Batch   int
+	Client  *http.Client
+}
+
+type hecEvent struct {
+	Time       float64           `json:"time"`
+	Host       string            `json:"host,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	SourceType string            `json:"sourcetype,omitempty"`
+	Index      string            `json:"index,omitempty"`
+	Fields     map[string]string `json:"fields"`
+	Event      interface{}       `json:"event"`
+}
+
+func (s *Splunk) Name() string {
+	return s.TargetName
+}
+func (s *Splunk) BatchSize() int {
+	if s.Batch > 0 {
+		return s.Batch
+	}
+	return 100
+}
+func (s *Splunk) Send(ctx context.Context, t secureWorks.Ticket) error {
+	return s.SendBatch(ctx, []secureWorks.Ticket{t})
+}
+
+/* Render returns the HEC request body: one JSON event per line */
+func (s *Splunk) Render(l []secureWorks.Ticket) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, t := range l {
+		for _, d := range Documents(t, s.Flatten) {
+			st := s.SourceType
+			if len(st) == 0 {
+				st = "secureworks:" + d.Kind
+			}
+			src := s.Source
+			if len(src) == 0 {
+				src = "secureworks"
+			}
+			err := enc.Encode(hecEvent{
+				Time:       float64(d.Time.UnixMilli()) / 1000,
+				Host:       t.Devices.Name,
+				Source:     src,
+				SourceType: st,
+				Index:      s.Index,
+				Fields:     map[string]string{"doc_id": d.Id, "ticket_id": t.TicketId},
+				Event:      d.Body,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+func (s *Splunk) SendBatch(ctx context.Context, l []secureWorks.Ticket) error {
+	body, err := s.Render(l)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		strings.TrimRight(s.URL, "/")+"/services/collector/event", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+s.Token)
+	if len(s.Channel) > 0 {
+		req.Header.Set("X-Splunk-Request-Channel", s.Channel)
+	}
+	return doRequest(s.Client, req, s.TargetName)
+}
@@ -0,0 +1,65 @@
+package forward
+
+import "bufio"
+import "bytes"
+import "context"
+import "encoding/json"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "secureWorks"
+import "strings"
+import "testing"
+
+func TestSplunkSendBatch(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		io.WriteString(w, `{"text":"Success","code":0}`)
+	}))
+	defer s.Close()
+
+	tk := secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "2", DateModified: 1700000500000,
+		Devices:  secureWorks.IdName{Name: "fw1"},
+		WorkLogs: []secureWorks.WorkLog{{DateCreated: 1700000000000, Type: "NOTE", Description: "opened"}}}
+	sp := &Splunk{TargetName: "hec", URL: s.URL + "/", Token: "tok", Index: "soc", Channel: "chan", Flatten: true}
+	if err := sp.SendBatch(context.Background(), []secureWorks.Ticket{tk, {TicketId: "INC-2", TicketVersion: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/services/collector/event" || got.Header.Get("Authorization") != "Splunk tok" ||
+		got.Header.Get("X-Splunk-Request-Channel") != "chan" {
+		t.Errorf("request %s %v", got.URL.Path, got.Header)
+	}
+
+	want := append(Documents(tk, true), Documents(secureWorks.Ticket{TicketId: "INC-2", TicketVersion: "1"}, true)...)
+	var events []hecEvent
+	for sc := bufio.NewScanner(bytes.NewReader(body)); sc.Scan(); {
+		var e hecEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%s: %v", sc.Bytes(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != len(want) {
+		t.Fatalf("%d events, want %d:\n%s", len(events), len(want), body)
+	}
+	for i, e := range events {
+		if e.Fields["doc_id"] != want[i].Id || e.Index != "soc" || e.Source != "secureworks" ||
+			e.SourceType != "secureworks:"+want[i].Kind {
+			t.Errorf("event %d\n got %+v\nwant id %s", i, e, want[i].Id)
+		}
+	}
+	if events[0].Time != 1700000500 || events[0].Host != "fw1" || events[1].Time != 1700000000 ||
+		events[1].Fields["ticket_id"] != "INC-1" {
+		t.Errorf("events %+v", events[:2])
+	}
+
+	status = http.StatusForbidden
+	if err := sp.Send(context.Background(), tk); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("non-2xx: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package forward
+
+import "context"
+import "secureWorks"
+import "secureWorks/siem"
+import "secureWorks/watch"
+import "sync"
+
+/*
+ * SyslogTarget sends each ticket version to a syslog collector as a CEF
+ * or LEEF message. With a Cursor it also sends one message per worklog:
+ * every worklog of a ticket seen for the first time, then only those
+ * newer than the last one sent.
+ */
+type SyslogTarget struct {
+	TargetName string
+	Syslog     *siem.Syslog
+	Format     string
+	Cursor     *watch.Cursor
+	mu         sync.Mutex
+}
+
+func (s *SyslogTarget) Name() string {
+	return s.TargetName
+}
+func (s *SyslogTarget) Send(ctx context.Context, t secureWorks.Ticket) error {
+	if s.Cursor == nil {
+		return s.Syslog.SendTicket(ctx, s.Format, t, nil)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, known := s.Cursor.Tickets[t.TicketId]
+	worklogs := t.WorkLogs
+	if known {
+		worklogs = nil
+		for _, e := range s.Cursor.Update([]secureWorks.Ticket{t}) {
+			if e.WorkLog != nil {
+				worklogs = append(worklogs, *e.WorkLog)
+			}
+		}
+	} else {
+		s.Cursor.Update([]secureWorks.Ticket{t})
+	}
+	if err := s.Syslog.SendTicket(ctx, s.Format, t, worklogs); err != nil {
+		/* resend the same worklogs on the next attempt */
+		if known {
+			s.Cursor.Tickets[t.TicketId] = prev
+		} else {
+			delete(s.Cursor.Tickets, t.TicketId)
+		}
+		return err
+	}
+	return s.Cursor.Save()
+}
@@ -0,0 +1,97 @@
+package forward
+
+import "bytes"
+import "context"
+import "crypto/hmac"
+import "crypto/sha256"
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "secureWorks"
+import "strconv"
+import "text/template"
+import "time"
+
+/*
+ * Webhook POSTs each ticket to URL. The body is the Template executed
+ * with the Ticket, or by default {"ticketId", "ticketVersion", "ticket"}
+ * as JSON. With a Secret, X-Signature-256 carries
+ * "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)) and
+ * X-Signature-Timestamp the unix timestamp signed, so receivers can
+ * reject replays.
+ */
+type Webhook struct {
+	TargetName  string
+	URL         string
+	Secret      string
+	Template    *template.Template
+	ContentType string
+	Headers     map[string]string
+	Client      *http.Client
+}
+
+func (w *Webhook) Name() string {
+	return w.TargetName
+}
+func (w *Webhook) Render(t secureWorks.Ticket) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(map[string]interface{}{
+			"ticketId":      t.TicketId,
+			"ticketVersion": t.TicketVersion,
+			"ticket":        t,
+		})
+	}
+	var b bytes.Buffer
+	err := w.Template.Execute(&b, t)
+	return b.Bytes(), err
+}
+func Sign(secret string, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(timestamp))
+	m.Write([]byte("."))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+func (w *Webhook) Send(ctx context.Context, t secureWorks.Ticket) error {
+	body, err := w.Render(t)
+	if err != nil {
+		return fmt.Errorf("%s: render: %v", w.TargetName, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ct := w.ContentType
+	if len(ct) == 0 {
+		ct = "application/json"
+	}
+	req.Header.Set("Content-Type", ct)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(w.Secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Signature-Timestamp", ts)
+		req.Header.Set("X-Signature-256", Sign(w.Secret, ts, body))
+	}
+	return doRequest(w.Client, req, w.TargetName)
+}
+
+/* doRequest treats anything but a 2xx response as a failed delivery */
+func doRequest(c *http.Client, req *http.Request, name string) error {
+	if c == nil {
+		c = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s: %s", name, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
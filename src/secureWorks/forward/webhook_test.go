@@ -0,0 +1,70 @@
+package forward
+
+import "context"
+import "crypto/hmac"
+import "crypto/sha256"
+import "encoding/hex"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "secureWorks"
+import "strconv"
+import "strings"
+import "testing"
+import "time"
+
+func TestSign(t *testing.T) {
+	/* python3: hmac.new(b"key", b'1700000000.{"a":1}', hashlib.sha256).hexdigest() */
+	want := "sha256=a438e398bfafc57e4396bb7fc2304422f0f768e965d073ca313cb52e22e6ad03"
+	if got := Sign("key", "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Errorf("Sign\n got %s\nwant %s", got, want)
+	}
+}
+func TestWebhookSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if r.Header.Get("X-Fail") != "" {
+			http.Error(w, "nope", http.StatusBadGateway)
+		}
+	}))
+	defer s.Close()
+
+	w := &Webhook{TargetName: "hook", URL: s.URL, Secret: "s3cret", Headers: map[string]string{"X-Team": "soc"}}
+	if err := w.Send(context.Background(), secureWorks.Ticket{TicketId: "INC-1", TicketVersion: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "POST" || got.Header.Get("Content-Type") != "application/json" || got.Header.Get("X-Team") != "soc" {
+		t.Errorf("request %s %v", got.Method, got.Header)
+	}
+	if !strings.Contains(string(body), `"ticketId":"INC-1"`) {
+		t.Errorf("body %s", body)
+	}
+	ts := got.Header.Get("X-Signature-Timestamp")
+	if n, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(n, 0)) > time.Minute {
+		t.Errorf("X-Signature-Timestamp %q", ts)
+	}
+	/* what a receiver does: HMAC the timestamp and the body as received */
+	m := hmac.New(sha256.New, []byte("s3cret"))
+	io.WriteString(m, ts+".")
+	m.Write(body)
+	if sig := got.Header.Get("X-Signature-256"); sig != "sha256="+hex.EncodeToString(m.Sum(nil)) {
+		t.Errorf("X-Signature-256 %q does not verify", sig)
+	}
+
+	/* no secret, no signature */
+	w.Secret = ""
+	if err := w.Send(context.Background(), secureWorks.Ticket{TicketId: "INC-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("X-Signature-256") != "" || got.Header.Get("X-Signature-Timestamp") != "" {
+		t.Errorf("unsigned request has %v", got.Header)
+	}
+
+	w.Headers["X-Fail"] = "1"
+	if err := w.Send(context.Background(), secureWorks.Ticket{TicketId: "INC-1"}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("non-2xx: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package intel
+
+import "fmt"
+import "secureWorks"
+import "secureWorks/ioc"
+import "strconv"
+import "strings"
+import "time"
+
+/* MISPEvent is the MISP event JSON format, as accepted by events/add and the importer */
+type MISPEvent struct {
+	Event MISPEventBody `json:"Event"`
+}
+type MISPEventBody struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	Timestamp     string          `json:"timestamp"`
+	ThreatLevelId string          `json:"threat_level_id"`
+	Analysis      string          `json:"analysis"`
+	Distribution  string          `json:"distribution"`
+	Tag           []MISPTag       `json:"Tag"`
+	Attribute     []MISPAttribute `json:"Attribute"`
+}
+type MISPTag struct {
+	Name string `json:"name"`
+}
+type MISPAttribute struct {
+	UUID     string `json:"uuid"`
+	Type     string `json:"type"`
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	ToIds    bool   `json:"to_ids"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+/* MISP type and category for each indicator type */
+var mispTypes = map[string][2]string{
+	ioc.IPv4:   {"ip-dst", "Network activity"},
+	ioc.IPv6:   {"ip-dst", "Network activity"},
+	ioc.Domain: {"domain", "Network activity"},
+	ioc.URL:    {"url", "Network activity"},
+	ioc.MD5:    {"md5", "Payload delivery"},
+	ioc.SHA1:   {"sha1", "Payload delivery"},
+	ioc.SHA256: {"sha256", "Payload delivery"},
+	ioc.CVE:    {"vulnerability", "External analysis"},
+}
+
+/* Sharing follows TLP: red stays in the organisation, white goes everywhere */
+var mispDistribution = map[string]string{"red": "0", "amber": "1", "green": "2", "white": "3"}
+
+/*
+ * MISP converts t into an event: indicators become attributes (flagged
+ * for IDS, except CVEs), the device becomes target-machine/ip-dst/
+ * target-location context attributes, and the event is tagged with the
+ * TLP, severity and ticket id. The event uuid derives from the ticket id,
+ * so a re-import updates the same event.
+ */
+func MISP(t secureWorks.Ticket, device *secureWorks.DeviceList, tlp string) MISPEvent {
+	if _, ok := mispDistribution[tlp]; !ok {
+		tlp = "amber"
+	}
+	e := MISPEventBody{
+		UUID:         ioc.UUID("misp:secureworks:" + t.TicketId),
+		Info:         "SecureWorks " + t.TicketId,
+		Timestamp:    strconv.FormatInt(time.Now().Unix(), 10),
+		Analysis:     "1",
+		Distribution: mispDistribution[tlp],
+		Attribute:    []MISPAttribute{},
+	}
+	if len(t.SymptomDescription) > 0 {
+		e.Info += ": " + t.SymptomDescription
+	}
+	date := secureWorks.TicketTime(t.DateCreated)
+	if date.IsZero() {
+		date = time.Now()
+	}
+	e.Date = date.UTC().Format("2006-01-02")
+	switch strings.ToUpper(t.Severity) {
+	case "CRITICAL", "HIGH":
+		e.ThreatLevelId = "1"
+	case "MEDIUM":
+		e.ThreatLevelId = "2"
+	case "LOW", "INFO", "INFORMATIONAL":
+		e.ThreatLevelId = "3"
+	default:
+		e.ThreatLevelId = "4"
+	}
+	if t.DateClosed != 0 || strings.EqualFold(t.Status, "CLOSED") {
+		e.Analysis = "2"
+	}
+	if tlp == "white" {
+		e.Tag = append(e.Tag, MISPTag{"tlp:clear"})
+	} else {
+		e.Tag = append(e.Tag, MISPTag{"tlp:" + tlp})
+	}
+	e.Tag = append(e.Tag, MISPTag{"secureworks:ticket=\"" + t.TicketId + "\""})
+	if len(t.Severity) > 0 {
+		e.Tag = append(e.Tag, MISPTag{"secureworks:severity=\"" + strings.ToLower(t.Severity) + "\""})
+	}
+
+	seen := map[string]bool{}
+	attr := func(typ string, category string, value string, ids bool, comment string) {
+		id := ioc.UUID(fmt.Sprintf("misp:%s:%s:%s", t.TicketId, typ, value))
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		e.Attribute = append(e.Attribute, MISPAttribute{
+			UUID: id, Type: typ, Category: category, Value: value, ToIds: ids, Comment: comment,
+		})
+	}
+	for _, i := range ioc.ExtractTicket(t) {
+		m := mispTypes[i.Type]
+		attr(m[0], m[1], i.Value, i.Type != ioc.CVE, "From "+strings.Join(i.Sources, ", "))
+	}
+	if device != nil {
+		comment := "Monitored device"
+		if len(device.DeviceAlias) > 0 {
+			comment += " " + device.DeviceAlias
+		}
+		attr("target-machine", "Targeting data", device.DeviceName, false, comment)
+		if len(device.DeviceIp) > 0 {
+			attr("ip-dst", "Network activity", device.DeviceIp, false, comment)
+		}
+		if len(device.Location.Name) > 0 {
+			attr("target-location", "Targeting data", device.Location.Name, false, comment)
+		}
+	}
+	return MISPEvent{Event: e}
+}
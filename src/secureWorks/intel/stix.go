@@ -0,0 +1,225 @@
+package intel
+
+import "encoding/json"
+import "fmt"
+import "secureWorks"
+import "secureWorks/ioc"
+import "strings"
+import "time"
+
+/* The TLP marking definitions published in the STIX 2.1 specification */
+var tlpMarkings = map[string]string{
+	"white": "marking-definition--613f2e26-407d-48c7-9eca-b8e91df99dc9",
+	"green": "marking-definition--34098fce-860f-48ae-8e50-ebd3cc5e41da",
+	"amber": "marking-definition--f88d31f6-486f-44da-b317-01333bde0b82",
+	"red":   "marking-definition--5e57c739-391a-4eb3-b6be-7d15ca92d5ed",
+}
+
+var producer = ioc.Object{
+	"type":           "identity",
+	"spec_version":   "2.1",
+	"id":             ioc.StixId("identity", "SecureWorks"),
+	"created":        "2024-01-01T00:00:00.000Z",
+	"modified":       "2024-01-01T00:00:00.000Z",
+	"name":           "SecureWorks",
+	"identity_class": "organization",
+}
+
+func tlpMarking(level string) ioc.Object {
+	return ioc.Object{
+		"type":            "marking-definition",
+		"spec_version":    "2.1",
+		"id":              tlpMarkings[level],
+		"created":         "2017-01-20T00:00:00.000Z",
+		"definition_type": "tlp",
+		"name":            "TLP:" + strings.ToUpper(level),
+		"definition":      ioc.Object{"tlp": level},
+	}
+}
+
+func stixTime(v int64) string {
+	t := secureWorks.TicketTime(v)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+/* sco builds a cyber observable with the id the spec derives from its value */
+func sco(typ string, props ioc.Object) ioc.Object {
+	b, _ := json.Marshal(props)
+	o := ioc.Object{"type": typ, "spec_version": "2.1", "id": ioc.StixId(typ, string(b))}
+	for k, v := range props {
+		o[k] = v
+	}
+	return o
+}
+func observable(i ioc.Indicator) ioc.Object {
+	switch i.Type {
+	case ioc.IPv4:
+		return sco("ipv4-addr", ioc.Object{"value": i.Value})
+	case ioc.IPv6:
+		return sco("ipv6-addr", ioc.Object{"value": i.Value})
+	case ioc.Domain:
+		return sco("domain-name", ioc.Object{"value": i.Value})
+	case ioc.URL:
+		return sco("url", ioc.Object{"value": i.Value})
+	case ioc.MD5:
+		return sco("file", ioc.Object{"hashes": ioc.Object{"MD5": i.Value}})
+	case ioc.SHA1:
+		return sco("file", ioc.Object{"hashes": ioc.Object{"SHA-1": i.Value}})
+	case ioc.SHA256:
+		return sco("file", ioc.Object{"hashes": ioc.Object{"SHA-256": i.Value}})
+	}
+	return nil
+}
+
+/*
+ * STIX converts t into a bundle: an incident for the ticket, the
+ * extracted indicators (and CVEs as vulnerabilities), an observed-data
+ * holding their observables, and the ticket's device as infrastructure
+ * when device is known. The incident is related-to everything else. All
+ * objects carry the TLP marking (white, green, amber or red) and are
+ * created by a SecureWorks identity. Ids are deterministic, so exporting
+ * a ticket again yields the same objects, with a newer modified time.
+ * Indicators, vulnerabilities and the device are shared between tickets:
+ * they are created at ioc.Epoch and hold nothing about the ticket, which
+ * is recorded on the incident's relationships to them instead.
+ */
+func STIX(t secureWorks.Ticket, device *secureWorks.DeviceList, tlp string) ioc.Bundle {
+	marking, ok := tlpMarkings[tlp]
+	if !ok {
+		tlp, marking = "amber", tlpMarkings["amber"]
+	}
+	created := stixTime(t.DateCreated)
+	modified := stixTime(t.DateModified)
+	if modified < created {
+		modified = created
+	}
+	common := func(o ioc.Object) ioc.Object {
+		o["object_marking_refs"] = []string{marking}
+		if _, sdo := o["created"]; sdo {
+			o["created_by_ref"] = producer["id"]
+		}
+		return o
+	}
+	objects := []ioc.Object{producer, tlpMarking(tlp)}
+	var related []string
+	var relationships []ioc.Object
+	relate := func(src string, rel string, dst string, created string, modified string) ioc.Object {
+		r := common(ioc.Object{
+			"type":              "relationship",
+			"spec_version":      "2.1",
+			"id":                ioc.StixId("relationship", src+" "+rel+" "+dst),
+			"created":           created,
+			"modified":          modified,
+			"relationship_type": rel,
+			"source_ref":        src,
+			"target_ref":        dst,
+		})
+		relationships = append(relationships, r)
+		return r
+	}
+
+	name := t.TicketId
+	if len(t.SymptomDescription) > 0 {
+		name += ": " + t.SymptomDescription
+	}
+	incident := common(ioc.Object{
+		"type":         "incident",
+		"spec_version": "2.1",
+		"id":           ioc.StixId("incident", "secureworks:"+t.TicketId),
+		"created":      created,
+		"modified":     modified,
+		"name":         name,
+		"description":  t.DetailedDescription,
+		"external_references": []ioc.Object{
+			{"source_name": "secureworks", "external_id": t.TicketId},
+		},
+	})
+	var labels []string
+	for _, l := range []string{t.TicketType, t.Severity, t.Status} {
+		if len(l) > 0 {
+			labels = append(labels, strings.ToLower(l))
+		}
+	}
+	if len(labels) > 0 {
+		incident["labels"] = labels
+	}
+	objects = append(objects, incident)
+
+	indicators := ioc.ExtractTicket(t)
+	var refs []string
+	seen := map[string]bool{}
+	addSCO := func(o ioc.Object) string {
+		id := o["id"].(string)
+		if !seen[id] {
+			seen[id] = true
+			objects = append(objects, common(o))
+			refs = append(refs, id)
+		}
+		return id
+	}
+	for _, i := range indicators {
+		if o := observable(i); o != nil {
+			addSCO(o)
+		}
+	}
+	for _, o := range ioc.STIXObjects(indicators) {
+		objects = append(objects, common(o))
+		related = append(related, o["id"].(string))
+	}
+
+	if device != nil {
+		var desc []string
+		if len(device.DeviceAlias) > 0 {
+			desc = append(desc, "Alias: "+device.DeviceAlias)
+		}
+		if len(device.DeviceIp) > 0 {
+			desc = append(desc, "IP: "+device.DeviceIp)
+		}
+		if len(device.Location.Name) > 0 {
+			desc = append(desc, "Location: "+device.Location.Name)
+		}
+		infra := common(ioc.Object{
+			"type":         "infrastructure",
+			"spec_version": "2.1",
+			"id":           ioc.StixId("infrastructure", fmt.Sprintf("secureworks:device:%d", device.DeviceId)),
+			"created":      ioc.Epoch,
+			"modified":     modified,
+			"name":         device.DeviceName,
+			"description":  strings.Join(desc, ", "),
+		})
+		objects = append(objects, infra)
+		related = append(related, infra["id"].(string))
+		if len(device.DeviceIp) > 0 {
+			typ := ioc.IPv4
+			if strings.Contains(device.DeviceIp, ":") {
+				typ = ioc.IPv6
+			}
+			ip := observable(ioc.Indicator{Type: typ, Value: device.DeviceIp})
+			relate(infra["id"].(string), "consists-of", addSCO(ip), ioc.Epoch, ioc.Epoch)
+		}
+	}
+
+	if len(refs) > 0 {
+		od := common(ioc.Object{
+			"type":            "observed-data",
+			"spec_version":    "2.1",
+			"id":              ioc.StixId("observed-data", "secureworks:observed:"+t.TicketId),
+			"created":         created,
+			"modified":        modified,
+			"first_observed":  created,
+			"last_observed":   modified,
+			"number_observed": 1,
+			"object_refs":     refs,
+		})
+		objects = append(objects, od)
+		related = append(related, od["id"].(string))
+	}
+	for _, id := range related {
+		r := relate(incident["id"].(string), "related-to", id, created, modified)
+		r["description"] = "Seen in SecureWorks ticket " + t.TicketId
+	}
+	return ioc.NewBundle(append(objects, relationships...))
+}
@@ -0,0 +1,47 @@
+package intel
+
+import "reflect"
+import "secureWorks"
+import "secureWorks/ioc"
+import "testing"
+
+func TestSTIXSharedObjects(t *testing.T) {
+	device := &secureWorks.DeviceList{DeviceId: 3, DeviceName: "fw1", DeviceIp: "10.0.0.1"}
+	byId := func(tk secureWorks.Ticket) map[string]ioc.Object {
+		m := map[string]ioc.Object{}
+		for _, o := range STIX(tk, device, "green").Objects {
+			m[o["id"].(string)] = o
+		}
+		return m
+	}
+	one := byId(secureWorks.Ticket{TicketId: "INC-1", SymptomDescription: "Beacon to 10.0.0.5 CVE-2021-44228",
+		DateCreated: 1700000000000, DateModified: 1700000500000})
+	two := byId(secureWorks.Ticket{TicketId: "INC-2", SymptomDescription: "Again 10.0.0.5 CVE-2021-44228",
+		DateCreated: 1710000000000, DateModified: 1710000500000})
+
+	incident := ioc.StixId("incident", "secureworks:INC-1")
+	var shared int
+	for id, o := range one {
+		switch o["type"] {
+		case "indicator", "vulnerability":
+			shared++
+			if !reflect.DeepEqual(o, two[id]) {
+				t.Errorf("%s differs between tickets\n%v\n%v", id, o, two[id])
+			}
+			if o["created"] != ioc.Epoch {
+				t.Errorf("%s created %v", id, o["created"])
+			}
+			r := one[ioc.StixId("relationship", incident+" related-to "+id)]
+			if r == nil || r["created"] != "2023-11-14T22:13:20.000Z" || r["description"] != "Seen in SecureWorks ticket INC-1" {
+				t.Errorf("relationship to %s: %v", id, r)
+			}
+		case "infrastructure":
+			if o["created"] != ioc.Epoch {
+				t.Errorf("infrastructure created %v", o["created"])
+			}
+		}
+	}
+	if shared != 2 {
+		t.Errorf("%d indicators/vulnerabilities, want 2", shared)
+	}
+}
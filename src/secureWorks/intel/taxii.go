@@ -0,0 +1,79 @@
+package intel
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "secureWorks/ioc"
+import "strings"
+import "time"
+
+/*
+ * TAXII adds objects to a TAXII 2.1 collection. URL is the collection
+ * endpoint (.../collections/<id>/); objects are POSTed to its objects/
+ * resource as an envelope. Token is sent as a bearer token, otherwise
+ * Username/Password as basic auth.
+ */
+type TAXII struct {
+	URL      string
+	Username string
+	Password string
+	Token    string
+	Client   *http.Client
+}
+
+const taxiiMediaType = "application/taxii+json;version=2.1"
+
+func (x *TAXII) Add(ctx context.Context, objects []ioc.Object) error {
+	b, err := json.Marshal(map[string]interface{}{"objects": objects})
+	if err != nil {
+		return err
+	}
+	u := strings.TrimRight(x.URL, "/") + "/objects/"
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", taxiiMediaType)
+	req.Header.Set("Accept", taxiiMediaType)
+	if len(x.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+x.Token)
+	} else if len(x.Username) > 0 {
+		req.SetBasicAuth(x.Username, x.Password)
+	}
+	c := x.Client
+	if c == nil {
+		c = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		return fmt.Errorf("taxii: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	/* A 202 carries a status resource; failures are listed there */
+	var st struct {
+		Status       string `json:"status"`
+		FailureCount int    `json:"failure_count"`
+		Failures     []struct {
+			Id      string `json:"id"`
+			Message string `json:"message"`
+		} `json:"failures"`
+	}
+	if json.Unmarshal(body, &st) == nil && st.FailureCount > 0 {
+		msg := ""
+		if len(st.Failures) > 0 {
+			msg = st.Failures[0].Id + ": " + st.Failures[0].Message
+		}
+		return fmt.Errorf("taxii: %d of %d objects failed, first %s", st.FailureCount, len(objects), msg)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package intel
+
+import "encoding/json"
+import "fmt"
+import "os"
+import "secureWorks"
+import "strconv"
+import "strings"
+
+/*
+ * TLP chooses the Traffic Light Protocol marking for each client's
+ * tickets. It is read from a JSON file keyed by client name or id:
+ *
+ *   {"default": "amber", "clients": {"Acme": "green", "42": "red"}}
+ *
+ * Levels are clear (or white), green, amber and red.
+ */
+type TLP struct {
+	Default string            `json:"default"`
+	Clients map[string]string `json:"clients"`
+}
+
+var tlpLevels = map[string]string{"clear": "white", "white": "white", "green": "green", "amber": "amber", "red": "red"}
+
+func LoadTLP(fileName string) (*TLP, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	p := &TLP{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if len(p.Default) == 0 {
+		p.Default = "amber"
+	}
+	for k, v := range p.Clients {
+		if _, ok := tlpLevels[strings.ToLower(v)]; !ok {
+			return nil, fmt.Errorf("%s: client %s: unknown TLP %q", fileName, k, v)
+		}
+	}
+	if _, ok := tlpLevels[strings.ToLower(p.Default)]; !ok {
+		return nil, fmt.Errorf("%s: unknown default TLP %q", fileName, p.Default)
+	}
+	return p, nil
+}
+
+/* Level returns the TLP of t's client as white, green, amber or red */
+func (p *TLP) Level(t secureWorks.Ticket) string {
+	if p == nil {
+		return "amber"
+	}
+	v, ok := p.Clients[t.Client.Name]
+	if !ok {
+		v, ok = p.Clients[strconv.Itoa(t.Client.Id)]
+	}
+	if !ok {
+		v = p.Default
+	}
+	if l, ok := tlpLevels[strings.ToLower(v)]; ok {
+		return l
+	}
+	return "amber"
+}
@@ -0,0 +1,240 @@
+package secureWorks
+
+import "context"
+import "encoding/json"
+import "os"
+import "sort"
+import "sync"
+import "time"
+
+/*
+ * Inventory caches GetDeviceList in a JSON file so tickets can be
+ * enriched with the full device record without a SOAP call per ticket.
+ * Lists are kept per ClientId/LocationId, since tenants of a FanOut share
+ * one Inventory, and fetched again once older than MaxAge. Only one
+ * refresh runs at a time, and after a failed one Devices waits Backoff
+ * (default a minute) before trying again. With q.Inventory set (config setting
+ * DeviceCache), GetTicketDetail and GetUpdates enrich their tickets
+ * automatically.
+ */
+type Inventory struct {
+	File    string
+	MaxAge  time.Duration
+	Backoff time.Duration
+	mu      sync.Mutex
+	refresh sync.Mutex
+	cache   map[string]inventoryCache
+	loaded  bool
+	failed  map[string]inventoryFailure
+}
+type inventoryCache struct {
+	ClientId   string       `json:"clientId"`
+	LocationId string       `json:"locationId"`
+	Fetched    time.Time    `json:"fetched"`
+	Devices    []DeviceList `json:"devices"`
+}
+type inventoryFailure struct {
+	at  time.Time
+	err error
+}
+
+func NewInventory(file string, maxAge time.Duration) *Inventory {
+	if maxAge <= 0 {
+		maxAge = time.Hour
+	}
+	return &Inventory{File: file, MaxAge: maxAge, Backoff: time.Minute}
+}
+func inventoryKey(clientId string, locationId string) string {
+	return clientId + "/" + locationId
+}
+
+/* load reads the file once: a list of caches, or the single cache older versions wrote */
+func (i *Inventory) load() {
+	if i.loaded {
+		return
+	}
+	i.loaded = true
+	i.cache = map[string]inventoryCache{}
+	b, err := os.ReadFile(i.File)
+	if err != nil {
+		return
+	}
+	var l []inventoryCache
+	if err := json.Unmarshal(b, &l); err != nil {
+		var c inventoryCache
+		if json.Unmarshal(b, &c) == nil {
+			l = append(l, c)
+		}
+	}
+	for _, c := range l {
+		i.cache[inventoryKey(c.ClientId, c.LocationId)] = c
+	}
+}
+func (i *Inventory) lookup(q Query) (inventoryCache, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.load()
+	c, ok := i.cache[inventoryKey(q.ClientId, q.LocationId)]
+	return c, ok
+}
+
+/* Refresh fetches the device list for q's ClientId/LocationId now and saves it */
+func (i *Inventory) Refresh(q Query) error {
+	i.refresh.Lock()
+	defer i.refresh.Unlock()
+	return i.fetch(q)
+}
+
+/* recent is the error of a refresh for q that failed less than Backoff ago */
+func (i *Inventory) recent(q Query) error {
+	backoff := i.Backoff
+	if backoff <= 0 {
+		backoff = time.Minute
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	f, ok := i.failed[inventoryKey(q.ClientId, q.LocationId)]
+	if !ok || time.Since(f.at) >= backoff {
+		return nil
+	}
+	return f.err
+}
+func (i *Inventory) fetch(q Query) error {
+	key := inventoryKey(q.ClientId, q.LocationId)
+	x, err := GetDeviceList(q)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err != nil {
+		if i.failed == nil {
+			i.failed = map[string]inventoryFailure{}
+		}
+		i.failed[key] = inventoryFailure{at: time.Now(), err: err}
+		return err
+	}
+	delete(i.failed, key)
+	i.load()
+	i.cache[key] = inventoryCache{ClientId: q.ClientId, LocationId: q.LocationId,
+		Fetched: time.Now(), Devices: x.Devices}
+	var l []inventoryCache
+	for _, c := range i.cache {
+		l = append(l, c)
+	}
+	sort.Slice(l, func(a, b int) bool {
+		return inventoryKey(l[a].ClientId, l[a].LocationId) < inventoryKey(l[b].ClientId, l[b].LocationId)
+	})
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := i.File + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.File)
+}
+
+/*
+ * Devices returns the cached list for q's ClientId/LocationId, refreshing
+ * it first when stale. Concurrent callers wait for a single refresh. If
+ * the refresh fails the stale list is returned along with the error; for
+ * Backoff after that, Devices returns them again without calling
+ * GetDeviceList.
+ */
+func (i *Inventory) Devices(q Query) ([]DeviceList, error) {
+	c, ok := i.lookup(q)
+	if ok && time.Since(c.Fetched) < i.MaxAge {
+		return c.Devices, nil
+	}
+	i.refresh.Lock()
+	defer i.refresh.Unlock()
+	/* another caller may have refreshed it while this one waited */
+	if c, ok = i.lookup(q); ok && time.Since(c.Fetched) < i.MaxAge {
+		return c.Devices, nil
+	}
+	err := i.recent(q)
+	if err == nil {
+		err = i.fetch(q)
+	}
+	if err != nil {
+		if ok {
+			return c.Devices, err
+		}
+		return nil, err
+	}
+	c, _ = i.lookup(q)
+	return c.Devices, nil
+}
+
+/* Fetched is when the list for q's ClientId/LocationId was last fetched (zero if never) */
+func (i *Inventory) Fetched(q Query) time.Time {
+	c, _ := i.lookup(q)
+	return c.Fetched
+}
+
+/* Cached is the saved lists of every ClientId/LocationId as is, for offline use */
+func (i *Inventory) Cached() []DeviceList {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.load()
+	var keys []string
+	for k := range i.cache {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var l []DeviceList
+	for _, k := range keys {
+		l = append(l, i.cache[k].Devices...)
+	}
+	return l
+}
+
+/* Run refreshes the list every MaxAge until ctx is done */
+func (i *Inventory) Run(ctx context.Context, q Query, errs func(error)) error {
+	q = q.WithContext(ctx)
+	for {
+		if err := i.Refresh(q); err != nil {
+			errs(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(i.MaxAge):
+		}
+	}
+}
+
+/* FindDevice returns the record of t's device: by id, or by name when the ticket has no id */
+func FindDevice(devices []DeviceList, t Ticket) *DeviceList {
+	for n, d := range devices {
+		if (t.Devices.Id != 0 && d.DeviceId == t.Devices.Id) ||
+			(t.Devices.Id == 0 && len(t.Devices.Name) > 0 && d.DeviceName == t.Devices.Name) {
+			return &devices[n]
+		}
+	}
+	return nil
+}
+
+/* Enrich copies the alias and IP of t's device into t; false if it is not listed */
+func (t *Ticket) Enrich(devices []DeviceList) bool {
+	d := FindDevice(devices, *t)
+	if d == nil {
+		return false
+	}
+	t.DeviceAlias = d.DeviceAlias
+	t.DeviceIp = d.DeviceIp
+	return true
+}
+
+/* enrich is called by the ticket operations; a failed device lookup is only logged */
+func (q Query) enrich(tickets []Ticket) {
+	if q.Inventory == nil || len(tickets) == 0 {
+		return
+	}
+	devices, err := q.Inventory.Devices(q)
+	if err != nil {
+		q.logger().Warn("device inventory refresh failed", "error", q.redactError(err))
+	}
+	for n := range tickets {
+		tickets[n].Enrich(devices)
+	}
+}
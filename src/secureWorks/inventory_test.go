@@ -0,0 +1,143 @@
+package secureWorks
+
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "regexp"
+import "sync"
+import "sync/atomic"
+import "testing"
+import "time"
+
+/* deviceServer answers getDeviceList with one device named after the clientId */
+func deviceServer(t *testing.T, calls *int32) *httptest.Server {
+	clientRe := regexp.MustCompile(`<clientId>([^<]*)</clientId>`)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		b, _ := io.ReadAll(r.Body)
+		client := ""
+		if m := clientRe.FindSubmatch(b); m != nil {
+			client = string(m[1])
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, `<Envelope><Body><getDeviceListResponse><device><deviceId>1</deviceId><deviceName>fw-`+
+			client+`</deviceName></device></getDeviceListResponse></Body></Envelope>`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+func TestInventorySingleRefresh(t *testing.T) {
+	var calls int32
+	s := deviceServer(t, &calls)
+	i := NewInventory(filepath.Join(t.TempDir(), "devices.json"), time.Hour)
+	q := Query{ApiUri: s.URL, ClientId: "7"}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l, err := i.Devices(q); err != nil || len(l) != 1 {
+				t.Errorf("Devices = %v, %v", l, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("%d getDeviceList calls, want 1", n)
+	}
+}
+func TestInventoryPerTenant(t *testing.T) {
+	var calls int32
+	s := deviceServer(t, &calls)
+	file := filepath.Join(t.TempDir(), "devices.json")
+	i := NewInventory(file, time.Hour)
+	a := Query{ApiUri: s.URL, ClientId: "7"}
+	b := Query{ApiUri: s.URL, ClientId: "8", LocationId: "2"}
+
+	for _, q := range []Query{a, b, a, b} {
+		l, err := i.Devices(q)
+		if err != nil || len(l) != 1 || l[0].DeviceName != "fw-"+q.ClientId {
+			t.Errorf("Devices(%s) = %v, %v", q.ClientId, l, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("%d getDeviceList calls, want 2", n)
+	}
+
+	/* a new Inventory reads both lists back, without fetching */
+	j := NewInventory(file, time.Hour)
+	if l := j.Cached(); len(l) != 2 || l[0].DeviceName != "fw-7" || l[1].DeviceName != "fw-8" {
+		t.Errorf("Cached = %v", l)
+	}
+	if j.Fetched(b).IsZero() || !j.Fetched(Query{ClientId: "9"}).IsZero() {
+		t.Error("Fetched per tenant")
+	}
+	if _, err := j.Devices(b); err != nil || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("cached list fetched again: %v", err)
+	}
+}
+func TestInventoryOldFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devices.json")
+	old := `{"clientId": "7", "locationId": "", "fetched": "2024-01-01T00:00:00Z", "devices": [{"DeviceName": "fw1"}]}`
+	if err := os.WriteFile(file, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+	i := NewInventory(file, time.Hour)
+	if l := i.Cached(); len(l) != 1 || l[0].DeviceName != "fw1" {
+		t.Errorf("Cached = %v", l)
+	}
+	/* stale, and the refresh fails: the old list is still returned */
+	l, err := i.Devices(Query{ApiUri: "http://127.0.0.1:1", ClientId: "7"})
+	if err == nil || len(l) != 1 {
+		t.Errorf("Devices = %v, %v", l, err)
+	}
+}
+func TestInventoryBackoff(t *testing.T) {
+	var calls, down int32 = 0, 1
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			io.WriteString(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Server</faultcode><faultstring>down</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+		io.WriteString(w, `<Envelope><Body><getDeviceListResponse><device><deviceId>1</deviceId><deviceName>fw1</deviceName>`+
+			`</device></getDeviceListResponse></Body></Envelope>`)
+	}))
+	defer s.Close()
+	i := NewInventory(filepath.Join(t.TempDir(), "devices.json"), time.Hour)
+	i.Backoff = 100 * time.Millisecond
+	q := Query{ApiUri: s.URL, ClientId: "7"}
+
+	/* one failed call, then the failure is answered from memory */
+	for n := 0; n < 5; n++ {
+		if l, err := i.Devices(q); err == nil || len(l) != 0 {
+			t.Errorf("Devices = %v, %v", l, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("%d getDeviceList calls during the backoff, want 1", n)
+	}
+	/* other tenants are not held back */
+	if _, err := i.Devices(Query{ApiUri: s.URL, ClientId: "8"}); err == nil || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("other tenant: %v, %d calls", err, atomic.LoadInt32(&calls))
+	}
+
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(i.Backoff)
+	if l, err := i.Devices(q); err != nil || len(l) != 1 || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("after the backoff: %v, %v, %d calls", l, err, atomic.LoadInt32(&calls))
+	}
+
+	/* Refresh is asked for explicitly, so it does not wait out a backoff */
+	atomic.StoreInt32(&down, 1)
+	if err := i.Refresh(q); err == nil {
+		t.Error("Refresh succeeded")
+	}
+	if err := i.Refresh(q); err == nil || atomic.LoadInt32(&calls) != 5 {
+		t.Errorf("Refresh: %v, %d calls", err, atomic.LoadInt32(&calls))
+	}
+}
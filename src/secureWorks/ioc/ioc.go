@@ -0,0 +1,194 @@
+package ioc
+
+import "net"
+import "regexp"
+import "secureWorks"
+import "sort"
+import "strings"
+
+/* Indicator types */
+const (
+	IPv4   = "ipv4"
+	IPv6   = "ipv6"
+	Domain = "domain"
+	URL    = "url"
+	MD5    = "md5"
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	CVE    = "cve"
+)
+
+/*
+ * Indicator is one observable found in a ticket. Value is always the
+ * refanged form; Sources are the ticket fields it was found in
+ * (SymptomDescription, DetailedDescription, WorkLog).
+ */
+type Indicator struct {
+	Type     string   `json:"type"`
+	Value    string   `json:"value"`
+	TicketId string   `json:"ticketId,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+}
+
+/* Common ways of defanging, undone before extraction */
+var refanger = strings.NewReplacer(
+	"[.]", ".", "(.)", ".", "{.}", ".", "[dot]", ".", "(dot)", ".", "[DOT]", ".",
+	"[:]", ":", "[://]", "://", "[/]", "/",
+	"[@]", "@", "[at]", "@", "(at)", "@",
+)
+var fangedScheme = regexp.MustCompile(`(?i)\b(?:h[tx]{2}ps?|f[tx]p)\[?:\]?//`)
+
+/* Refang turns hxxp://evil[.]example back into http://evil.example */
+func Refang(s string) string {
+	s = refanger.Replace(s)
+	return fangedScheme.ReplaceAllStringFunc(s, func(m string) string {
+		switch {
+		case m[0] == 'f' || m[0] == 'F':
+			return "ftp://"
+		case m[4] == 's' || m[4] == 'S':
+			return "https://"
+		}
+		return "http://"
+	})
+}
+
+/* Defang makes a value safe to paste: hxxp, [.] and [:] */
+func Defang(typ string, v string) string {
+	switch typ {
+	case IPv4, Domain:
+		return strings.ReplaceAll(v, ".", "[.]")
+	case IPv6:
+		return strings.ReplaceAll(v, ":", "[:]")
+	case URL:
+		scheme, rest, ok := strings.Cut(v, "://")
+		if !ok {
+			return strings.ReplaceAll(v, ".", "[.]")
+		}
+		switch strings.ToLower(scheme) {
+		case "http":
+			scheme = "hxxp"
+		case "https":
+			scheme = "hxxps"
+		case "ftp":
+			scheme = "fxp"
+		}
+		host, path, _ := strings.Cut(rest, "/")
+		if len(path) > 0 || strings.HasSuffix(rest, "/") {
+			path = "/" + path
+		}
+		return scheme + "://" + strings.ReplaceAll(host, ".", "[.]") + path
+	}
+	return v
+}
+
+var (
+	urlRe    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'\x60\]\)}]+`)
+	ipv4Re   = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Re   = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+	domainRe = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+	hashRe   = regexp.MustCompile(`(?i)\b[0-9a-f]{32}(?:[0-9a-f]{8}(?:[0-9a-f]{24})?)?\b`)
+	cveRe    = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+)
+
+/*
+ * File names look like domains ("a.exe"); names whose last label is a
+ * common file extension are not reported as domains.
+ */
+var fileExtensions = map[string]bool{
+	"exe": true, "dll": true, "sys": true, "bat": true, "cmd": true, "ps1": true,
+	"vbs": true, "js": true, "jar": true, "py": true, "sh": true, "msi": true,
+	"zip": true, "rar": true, "7z": true, "gz": true, "tar": true, "iso": true,
+	"doc": true, "docx": true, "docm": true, "xls": true, "xlsx": true, "xlsm": true,
+	"ppt": true, "pptx": true, "pdf": true, "rtf": true, "txt": true, "log": true,
+	"csv": true, "xml": true, "json": true, "html": true, "htm": true, "php": true,
+	"asp": true, "aspx": true, "jsp": true, "png": true, "jpg": true, "jpeg": true,
+	"gif": true, "bmp": true, "tmp": true, "dat": true, "bin": true, "lnk": true,
+	"hta": true, "scr": true, "ini": true, "cfg": true, "conf": true, "eml": true,
+}
+
+/* Extract returns the indicators in text, in order of first appearance */
+func Extract(text string) []Indicator {
+	text = Refang(text)
+	type hit struct {
+		pos int
+		Indicator
+	}
+	var hits []hit
+	add := func(pos int, typ string, v string) {
+		hits = append(hits, hit{pos, Indicator{Type: typ, Value: v}})
+	}
+	for _, m := range urlRe.FindAllStringIndex(text, -1) {
+		add(m[0], URL, strings.TrimRight(text[m[0]:m[1]], ".,;:!?"))
+	}
+	for _, m := range ipv4Re.FindAllStringIndex(text, -1) {
+		v := text[m[0]:m[1]]
+		if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+			add(m[0], IPv4, v)
+		}
+	}
+	for _, m := range ipv6Re.FindAllStringIndex(text, -1) {
+		v := text[m[0]:m[1]]
+		if ip := net.ParseIP(v); ip != nil && ip.To4() == nil && len(strings.Trim(v, ":")) > 0 {
+			add(m[0], IPv6, strings.ToLower(v))
+		}
+	}
+	for _, m := range domainRe.FindAllStringIndex(text, -1) {
+		v := strings.ToLower(text[m[0]:m[1]])
+		tld := v[strings.LastIndex(v, ".")+1:]
+		if fileExtensions[tld] || net.ParseIP(v) != nil {
+			continue
+		}
+		add(m[0], Domain, v)
+	}
+	for _, m := range hashRe.FindAllStringIndex(text, -1) {
+		v := strings.ToLower(text[m[0]:m[1]])
+		typ := map[int]string{32: MD5, 40: SHA1, 64: SHA256}[len(v)]
+		add(m[0], typ, v)
+	}
+	for _, m := range cveRe.FindAllStringIndex(text, -1) {
+		add(m[0], CVE, strings.ToUpper(text[m[0]:m[1]]))
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].pos < hits[j].pos })
+	seen := map[string]bool{}
+	var l []Indicator
+	for _, h := range hits {
+		k := h.Type + "\x00" + h.Value
+		if !seen[k] {
+			seen[k] = true
+			l = append(l, h.Indicator)
+		}
+	}
+	return l
+}
+
+/* ExtractTicket extracts from the symptom, description and every worklog of t */
+func ExtractTicket(t secureWorks.Ticket) []Indicator {
+	var l []Indicator
+	index := map[string]int{}
+	scan := func(source string, text string) {
+		for _, i := range Extract(text) {
+			k := i.Type + "\x00" + i.Value
+			n, ok := index[k]
+			if !ok {
+				i.TicketId = t.TicketId
+				l = append(l, i)
+				n = len(l) - 1
+				index[k] = n
+			}
+			found := false
+			for _, s := range l[n].Sources {
+				found = found || s == source
+			}
+			if !found {
+				l[n].Sources = append(l[n].Sources, source)
+			}
+		}
+	}
+	scan("SymptomDescription", t.SymptomDescription)
+	scan("DetailedDescription", t.DetailedDescription)
+	for _, w := range t.WorkLogs {
+		scan("WorkLog", w.Description)
+	}
+	return l
+}
@@ -0,0 +1,81 @@
+package ioc
+
+import "reflect"
+import "secureWorks"
+import "testing"
+
+func TestRefang(t *testing.T) {
+	for in, want := range map[string]string{
+		"hxxps://evil[.]example[.]com/a": "https://evil.example.com/a",
+		"HXXP[:]//evil(.)example":        "http://evil.example",
+		"fxp://files[dot]example":        "ftp://files.example",
+		"user[@]corp{.}example":          "user@corp.example",
+		"10[.]0[.]0[.]5":                 "10.0.0.5",
+		"plain text":                     "plain text",
+	} {
+		if got := Refang(in); got != want {
+			t.Errorf("Refang(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+func TestDefang(t *testing.T) {
+	for _, c := range []struct{ typ, in, want string }{
+		{IPv4, "10.0.0.5", "10[.]0[.]0[.]5"},
+		{IPv6, "fe80::1", "fe80[:][:]1"},
+		{Domain, "evil.example", "evil[.]example"},
+		{URL, "https://evil.example/a.b/c", "hxxps://evil[.]example/a.b/c"},
+		{URL, "http://evil.example/", "hxxp://evil[.]example/"},
+		{MD5, "d41d8cd98f00b204e9800998ecf8427e", "d41d8cd98f00b204e9800998ecf8427e"},
+	} {
+		got := Defang(c.typ, c.in)
+		if got != c.want {
+			t.Errorf("Defang(%s, %q) = %q, want %q", c.typ, c.in, got, c.want)
+		}
+		if c.typ != IPv6 && Refang(got) != c.in {
+			t.Errorf("Refang(Defang(%q)) = %q", c.in, Refang(got))
+		}
+	}
+}
+func TestExtract(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []Indicator
+	}{
+		{"Beacon to hxxps://evil[.]example[.]com/path?q=1. from 10.0.0.5, see cve-2021-44228", []Indicator{
+			{Type: URL, Value: "https://evil.example.com/path?q=1"},
+			{Type: Domain, Value: "evil.example.com"},
+			{Type: IPv4, Value: "10.0.0.5"},
+			{Type: CVE, Value: "CVE-2021-44228"},
+		}},
+		/* file names are not domains; hashes are lowercased */
+		{"dropper a.exe and invoice.pdf; hash D41D8CD98F00B204E9800998ECF8427E", []Indicator{
+			{Type: MD5, Value: "d41d8cd98f00b204e9800998ecf8427e"},
+		}},
+		/* times and out of range octets are not addresses */
+		{"fe80::1 and 2001:DB8::ff00:42:8329 at 12:30:45, not 999.1.1.1", []Indicator{
+			{Type: IPv6, Value: "fe80::1"},
+			{Type: IPv6, Value: "2001:db8::ff00:42:8329"},
+		}},
+		{"da39a3ee5e6b4b0d3255bfef95601890afd80709 e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", []Indicator{
+			{Type: SHA1, Value: "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+			{Type: SHA256, Value: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		}},
+		{"evil.com EVIL.com evil[.]com", []Indicator{{Type: Domain, Value: "evil.com"}}},
+		{"nothing here", nil},
+	} {
+		if got := Extract(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Extract(%q)\n got %+v\nwant %+v", c.in, got, c.want)
+		}
+	}
+}
+func TestExtractTicket(t *testing.T) {
+	tk := secureWorks.Ticket{TicketId: "INC-1", SymptomDescription: "Beacon to 10.0.0.5",
+		WorkLogs: []secureWorks.WorkLog{{Description: "10.0.0.5 again"}, {Description: "and 10.0.0.5 evil.example"}}}
+	want := []Indicator{
+		{Type: IPv4, Value: "10.0.0.5", TicketId: "INC-1", Sources: []string{"SymptomDescription", "WorkLog"}},
+		{Type: Domain, Value: "evil.example", TicketId: "INC-1", Sources: []string{"WorkLog"}},
+	}
+	if got := ExtractTicket(tk); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractTicket\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package ioc
+
+import "crypto/rand"
+import "crypto/sha1"
+import "fmt"
+import "secureWorks"
+import "strings"
+import "time"
+
+/* Object is one STIX 2.1 object, kept generic so callers can add properties */
+type Object map[string]interface{}
+
+type Bundle struct {
+	Type    string   `json:"type"`
+	Id      string   `json:"id"`
+	Objects []Object `json:"objects"`
+}
+
+func NewBundle(objects []Object) Bundle {
+	if objects == nil {
+		objects = []Object{}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return Bundle{Type: "bundle", Id: "bundle--" + uuidString(b), Objects: objects}
+}
+
+/* The STIX 2.1 namespace for deterministic (UUIDv5) identifiers */
+var stixNamespace = []byte{0x00, 0xab, 0xed, 0xb4, 0xaa, 0x42, 0x46, 0x6c,
+	0x9c, 0x01, 0xfe, 0xd2, 0x33, 0x15, 0xa9, 0xb7}
+
+/* UUID is the UUIDv5 of name in the STIX namespace */
+func UUID(name string) string {
+	h := sha1.New()
+	h.Write(stixNamespace)
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return uuidString(b)
+}
+
+/* StixId derives "<typ>--<uuid5>" from name, so re-exports keep their ids */
+func StixId(typ string, name string) string {
+	return typ + "--" + UUID(name)
+}
+func uuidString(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+/* Pattern is the STIX pattern matching i; CVEs have none (they are vulnerabilities) */
+func Pattern(i Indicator) string {
+	q := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(i.Value)
+	switch i.Type {
+	case IPv4:
+		return "[ipv4-addr:value = '" + q + "']"
+	case IPv6:
+		return "[ipv6-addr:value = '" + q + "']"
+	case Domain:
+		return "[domain-name:value = '" + q + "']"
+	case URL:
+		return "[url:value = '" + q + "']"
+	case MD5:
+		return "[file:hashes.MD5 = '" + q + "']"
+	case SHA1:
+		return "[file:hashes.'SHA-1' = '" + q + "']"
+	case SHA256:
+		return "[file:hashes.'SHA-256' = '" + q + "']"
+	}
+	return ""
+}
+func stixTime(v int64) string {
+	t := secureWorks.TicketTime(v)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+/*
+ * Epoch is the created and modified time of objects shared between
+ * tickets. Their ids derive from the value, so a re-export from another
+ * ticket must produce the very same object, not a conflicting version;
+ * it predates any ticket so that later modified times stay valid.
+ */
+const Epoch = "2000-01-01T00:00:00.000Z"
+
+/*
+ * STIXObjects converts indicators into STIX indicator objects, and CVEs
+ * into vulnerability objects. Ids derive from the value, so one indicator
+ * seen in several tickets is one object; nothing in it is ticket
+ * specific (see Sightings for that).
+ */
+func STIXObjects(l []Indicator) []Object {
+	var objs []Object
+	for _, i := range l {
+		if i.Type == CVE {
+			objs = append(objs, Object{
+				"type":         "vulnerability",
+				"spec_version": "2.1",
+				"id":           StixId("vulnerability", i.Value),
+				"created":      Epoch,
+				"modified":     Epoch,
+				"name":         i.Value,
+				"external_references": []Object{
+					{"source_name": "cve", "external_id": i.Value},
+				},
+			})
+			continue
+		}
+		objs = append(objs, Object{
+			"type":         "indicator",
+			"spec_version": "2.1",
+			"id":           StixId("indicator", i.Type+":"+i.Value),
+			"created":      Epoch,
+			"modified":     Epoch,
+			"name":         i.Value,
+			"pattern":      Pattern(i),
+			"pattern_type": "stix",
+			"valid_from":   Epoch,
+		})
+	}
+	return objs
+}
+
+/*
+ * Sightings records that the objects from STIXObjects were seen in
+ * ticket t: one sighting per object and ticket, over the ticket's
+ * lifetime and referencing the ticket.
+ */
+func Sightings(t secureWorks.Ticket, l []Indicator) []Object {
+	created := stixTime(t.DateCreated)
+	modified := stixTime(t.DateModified)
+	if modified < created {
+		modified = created
+	}
+	var objs []Object
+	for _, o := range STIXObjects(l) {
+		id := o["id"].(string)
+		objs = append(objs, Object{
+			"type":            "sighting",
+			"spec_version":    "2.1",
+			"id":              StixId("sighting", "secureworks:"+t.TicketId+" "+id),
+			"created":         created,
+			"modified":        modified,
+			"first_seen":      created,
+			"last_seen":       modified,
+			"count":           1,
+			"sighting_of_ref": id,
+			"description":     "Seen in SecureWorks ticket " + t.TicketId,
+			"external_references": []Object{
+				{"source_name": "secureworks", "external_id": t.TicketId},
+			},
+		})
+	}
+	return objs
+}
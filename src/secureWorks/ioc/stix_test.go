@@ -0,0 +1,57 @@
+package ioc
+
+import "reflect"
+import "secureWorks"
+import "testing"
+
+func TestSTIXObjectsShared(t *testing.T) {
+	l := []Indicator{{Type: IPv4, Value: "10.0.0.5"}, {Type: CVE, Value: "CVE-2021-44228"}}
+	one := secureWorks.Ticket{TicketId: "INC-1", DateCreated: 1700000000000, DateModified: 1700000500000}
+	two := secureWorks.Ticket{TicketId: "INC-2", DateCreated: 1710000000000, DateModified: 1710000500000}
+
+	objs := STIXObjects(l)
+	if len(objs) != 2 || objs[0]["type"] != "indicator" || objs[1]["type"] != "vulnerability" {
+		t.Fatalf("STIXObjects = %v", objs)
+	}
+	if objs[0]["pattern"] != "[ipv4-addr:value = '10.0.0.5']" || objs[0]["created"] != Epoch || objs[0]["valid_from"] != Epoch {
+		t.Errorf("indicator = %v", objs[0])
+	}
+
+	s1, s2 := Sightings(one, l), Sightings(two, l)
+	if len(s1) != 2 || len(s2) != 2 {
+		t.Fatalf("Sightings = %v, %v", s1, s2)
+	}
+	for n, o := range objs {
+		if s1[n]["sighting_of_ref"] != o["id"] || s2[n]["sighting_of_ref"] != o["id"] {
+			t.Errorf("sighting %d does not reference %v", n, o["id"])
+		}
+		if s1[n]["id"] == s2[n]["id"] {
+			t.Errorf("sighting %d has the same id for both tickets", n)
+		}
+	}
+	want := Object{
+		"type":            "sighting",
+		"spec_version":    "2.1",
+		"id":              StixId("sighting", "secureworks:INC-1 "+objs[0]["id"].(string)),
+		"created":         "2023-11-14T22:13:20.000Z",
+		"modified":        "2023-11-14T22:21:40.000Z",
+		"first_seen":      "2023-11-14T22:13:20.000Z",
+		"last_seen":       "2023-11-14T22:21:40.000Z",
+		"count":           1,
+		"sighting_of_ref": objs[0]["id"],
+		"description":     "Seen in SecureWorks ticket INC-1",
+		"external_references": []Object{
+			{"source_name": "secureworks", "external_id": "INC-1"},
+		},
+	}
+	if !reflect.DeepEqual(s1[0], want) {
+		t.Errorf("sighting\n got %v\nwant %v", s1[0], want)
+	}
+}
+func TestStixId(t *testing.T) {
+	/* UUIDv5 in the STIX namespace, as computed by other STIX libraries */
+	want := "ipv4-addr--28bb3599-77cd-5a82-a950-b5bc3caf07c4"
+	if got := StixId("ipv4-addr", `{"value":"198.51.100.3"}`); got != want {
+		t.Errorf("StixId = %q, want %q", got, want)
+	}
+}
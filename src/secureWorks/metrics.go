@@ -0,0 +1,175 @@
+package secureWorks
+
+import "fmt"
+import "io"
+import "net/http"
+import "sort"
+import "strings"
+import "sync"
+import "time"
+
+/*
+ * Metrics receives one call per SOAP operation from makeSOAPrequest.
+ * status is 0 and bytes is 0 when the request never got a response.
+ */
+type Metrics interface {
+	ObserveRequest(operation string, duration time.Duration, status int, bytes int, err error)
+	ObserveFault(operation string, faultCode string)
+	ObserveRetry(operation string)
+}
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, time.Duration, int, int, error) {}
+func (nopMetrics) ObserveFault(string, string)                           {}
+func (nopMetrics) ObserveRetry(string)                                   {}
+func (q Query) metrics() Metrics {
+	if q.Metrics == nil {
+		return nopMetrics{}
+	}
+	return q.Metrics
+}
+
+/* Latency histogram buckets, in seconds */
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+/*
+ * PrometheusMetrics implements Metrics and serves what it has collected
+ * in the Prometheus text exposition format. SetQueueDepth adds the
+ * secureworks_queue_depth gauge used by the queue exporter.
+ */
+type PrometheusMetrics struct {
+	mu         sync.Mutex
+	requests   map[[2]string]uint64
+	latency    map[string]*histogram
+	faults     map[[2]string]uint64
+	retries    map[string]uint64
+	bytes      map[string]uint64
+	queueDepth map[string]float64
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		requests:   map[[2]string]uint64{},
+		latency:    map[string]*histogram{},
+		faults:     map[[2]string]uint64{},
+		retries:    map[string]uint64{},
+		bytes:      map[string]uint64{},
+		queueDepth: map[string]float64{},
+	}
+}
+func (p *PrometheusMetrics) ObserveRequest(operation string, duration time.Duration, status int, bytes int, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[[2]string{operation, result}]++
+	p.bytes[operation] += uint64(bytes)
+	h := p.latency[operation]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		p.latency[operation] = h
+	}
+	secs := duration.Seconds()
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+func (p *PrometheusMetrics) ObserveFault(operation string, faultCode string) {
+	p.mu.Lock()
+	p.faults[[2]string{operation, faultCode}]++
+	p.mu.Unlock()
+}
+func (p *PrometheusMetrics) ObserveRetry(operation string) {
+	p.mu.Lock()
+	p.retries[operation]++
+	p.mu.Unlock()
+}
+func (p *PrometheusMetrics) SetQueueDepth(ticketType string, depth int) {
+	p.mu.Lock()
+	p.queueDepth[ticketType] = float64(depth)
+	p.mu.Unlock()
+}
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.WriteTo(w)
+}
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.WriteString("# HELP secureworks_requests_total SOAP operations by result.\n")
+	b.WriteString("# TYPE secureworks_requests_total counter\n")
+	for _, k := range sortedPairs(p.requests) {
+		fmt.Fprintf(&b, "secureworks_requests_total{operation=%q,result=%q} %d\n", k[0], k[1], p.requests[k])
+	}
+	b.WriteString("# HELP secureworks_request_duration_seconds SOAP operation latency, including retries.\n")
+	b.WriteString("# TYPE secureworks_request_duration_seconds histogram\n")
+	for _, op := range sortedKeys(p.latency) {
+		h := p.latency[op]
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(&b, "secureworks_request_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n", op, le, h.counts[i])
+		}
+		fmt.Fprintf(&b, "secureworks_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", op, h.count)
+		fmt.Fprintf(&b, "secureworks_request_duration_seconds_sum{operation=%q} %g\n", op, h.sum)
+		fmt.Fprintf(&b, "secureworks_request_duration_seconds_count{operation=%q} %d\n", op, h.count)
+	}
+	b.WriteString("# HELP secureworks_faults_total SOAP faults by faultInfo code.\n")
+	b.WriteString("# TYPE secureworks_faults_total counter\n")
+	for _, k := range sortedPairs(p.faults) {
+		fmt.Fprintf(&b, "secureworks_faults_total{operation=%q,fault_code=%q} %d\n", k[0], k[1], p.faults[k])
+	}
+	b.WriteString("# HELP secureworks_retries_total SOAP request retries.\n")
+	b.WriteString("# TYPE secureworks_retries_total counter\n")
+	for _, op := range sortedKeys(p.retries) {
+		fmt.Fprintf(&b, "secureworks_retries_total{operation=%q} %d\n", op, p.retries[op])
+	}
+	b.WriteString("# HELP secureworks_response_bytes_total SOAP response bytes received.\n")
+	b.WriteString("# TYPE secureworks_response_bytes_total counter\n")
+	for _, op := range sortedKeys(p.bytes) {
+		fmt.Fprintf(&b, "secureworks_response_bytes_total{operation=%q} %d\n", op, p.bytes[op])
+	}
+	if len(p.queueDepth) > 0 {
+		b.WriteString("# HELP secureworks_queue_depth Tickets in queue as reported by getQueueCount.\n")
+		b.WriteString("# TYPE secureworks_queue_depth gauge\n")
+		for _, t := range sortedKeys(p.queueDepth) {
+			fmt.Fprintf(&b, "secureworks_queue_depth{ticket_type=%q} %g\n", t, p.queueDepth[t])
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+func sortedPairs(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
@@ -0,0 +1,77 @@
+package secureWorks
+
+import "errors"
+import "net/http/httptest"
+import "strings"
+import "testing"
+import "time"
+
+func TestPrometheusMetrics(t *testing.T) {
+	p := NewPrometheusMetrics()
+	p.ObserveRequest("getUpdates", 300*time.Millisecond, 200, 1000, nil)
+	p.ObserveRequest("getUpdates", 3*time.Second, 0, 0, errors.New("timeout"))
+	p.ObserveRequest("getTicketDetail", 50*time.Millisecond, 200, 24, nil)
+	p.ObserveRetry("getUpdates")
+	p.ObserveFault("getTicketDetail", "TICKET_NOT_FOUND")
+
+	var b strings.Builder
+	p.WriteTo(&b)
+	want := `# HELP secureworks_requests_total SOAP operations by result.
+# TYPE secureworks_requests_total counter
+secureworks_requests_total{operation="getTicketDetail",result="ok"} 1
+secureworks_requests_total{operation="getUpdates",result="error"} 1
+secureworks_requests_total{operation="getUpdates",result="ok"} 1
+# HELP secureworks_request_duration_seconds SOAP operation latency, including retries.
+# TYPE secureworks_request_duration_seconds histogram
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="0.1"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="0.25"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="0.5"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="1"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="2.5"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="5"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="10"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="30"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="60"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="300"} 1
+secureworks_request_duration_seconds_bucket{operation="getTicketDetail",le="+Inf"} 1
+secureworks_request_duration_seconds_sum{operation="getTicketDetail"} 0.05
+secureworks_request_duration_seconds_count{operation="getTicketDetail"} 1
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="0.1"} 0
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="0.25"} 0
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="0.5"} 1
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="1"} 1
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="2.5"} 1
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="5"} 2
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="10"} 2
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="30"} 2
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="60"} 2
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="300"} 2
+secureworks_request_duration_seconds_bucket{operation="getUpdates",le="+Inf"} 2
+secureworks_request_duration_seconds_sum{operation="getUpdates"} 3.3
+secureworks_request_duration_seconds_count{operation="getUpdates"} 2
+# HELP secureworks_faults_total SOAP faults by faultInfo code.
+# TYPE secureworks_faults_total counter
+secureworks_faults_total{operation="getTicketDetail",fault_code="TICKET_NOT_FOUND"} 1
+# HELP secureworks_retries_total SOAP request retries.
+# TYPE secureworks_retries_total counter
+secureworks_retries_total{operation="getUpdates"} 1
+# HELP secureworks_response_bytes_total SOAP response bytes received.
+# TYPE secureworks_response_bytes_total counter
+secureworks_response_bytes_total{operation="getTicketDetail"} 24
+secureworks_response_bytes_total{operation="getUpdates"} 1000
+`
+	if b.String() != want {
+		t.Errorf("WriteTo\n got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	/* the queue gauge only appears once set, and is served over HTTP */
+	p.SetQueueDepth("INCIDENT", 4)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if !strings.HasSuffix(w.Body.String(), "# TYPE secureworks_queue_depth gauge\nsecureworks_queue_depth{ticket_type=\"INCIDENT\"} 4\n") {
+		t.Errorf("queue depth missing:\n%s", w.Body.String())
+	}
+}
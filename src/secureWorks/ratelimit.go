@@ -0,0 +1,59 @@
+package secureWorks
+
+import "context"
+import "sync"
+import "time"
+
+/*
+ * RateLimit (config setting, requests per second) spaces out SOAP
+ * requests, retries included. Copies of a Query share one limiter, so
+ * the limit holds across goroutines such as GetTicketDetails workers or
+ * a FanOut. A Query built by hand gets one with WithRateLimit. The limit
+ * is per process: separate processes (or hosts) using the same account
+ * each get the full rate, so it does not by itself keep them under the
+ * server's limit.
+ */
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+/* WithRateLimit returns a copy of q limited to rps requests per second (0: unlimited) */
+func (q Query) WithRateLimit(rps float64) Query {
+	q.RateLimit = rps
+	q.limiter = nil
+	if rps > 0 {
+		q.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+	}
+	return q
+}
+
+/*
+ * wait blocks until a request may be sent, or ctx is done. A slot is only
+ * taken when it is free, so a caller that gives up holds none back from
+ * the others.
+ */
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if !now.Before(l.next) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		d := l.next.Sub(now)
+		l.mu.Unlock()
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
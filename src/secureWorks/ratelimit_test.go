@@ -0,0 +1,48 @@
+package secureWorks
+
+import "context"
+import "sync"
+import "testing"
+import "time"
+
+func TestRateLimit(t *testing.T) {
+	l := Query{}.WithRateLimit(20).limiter
+	start := time.Now()
+	for n := 0; n < 5; n++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("5 requests at 20/s took %v", d)
+	}
+	if q := (Query{}).WithRateLimit(0); q.limiter.wait(context.Background()) != nil {
+		t.Error("unlimited query waited")
+	}
+}
+func TestRateLimitCancelled(t *testing.T) {
+	l := Query{}.WithRateLimit(10).limiter
+	l.wait(context.Background())
+
+	/* callers that give up while waiting must not push later ones back */
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.wait(ctx); err == nil {
+				t.Error("wait got a slot within 10ms at 10/s")
+			}
+		}()
+	}
+	wg.Wait()
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("wait after cancelled callers took %v", d)
+	}
+}
@@ -0,0 +1,87 @@
+package secureWorks
+
+import "regexp"
+import "strings"
+import "sync"
+
+const redacted = "********"
+
+/* Elements whose contents are always scrubbed, namespace prefix ignored */
+var sensitiveElements = []string{"userName", "password", "Username", "Password", "Nonce"}
+
+/* Compiled element patterns, keyed by the config's SensitiveFields */
+var redactors sync.Map
+
+/* The SOAP Header, where the WS-Security token travels */
+var headerRe = regexp.MustCompile(`(?s)<(?:[\w.-]+:)?Header(?:\s[^>]*)?>.*?</(?:[\w.-]+:)?Header>`)
+
+func (q Query) redactor() *regexp.Regexp {
+	key := strings.Join(q.SensitiveFields, "\x00")
+	if re, ok := redactors.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+	names := append([]string{}, sensitiveElements...)
+	names = append(names, q.SensitiveFields...)
+	for i, v := range names {
+		names[i] = regexp.QuoteMeta(v)
+	}
+	re := regexp.MustCompile(`(<(?:[\w.-]+:)?(?:` + strings.Join(names, "|") +
+		`)(?:\s[^>]*)?>)[^<]*(</)`)
+	redactors.Store(key, re)
+	return re
+}
+
+/*
+ * Redact scrubs credentials from captured XML headed for a log or kept as
+ * RawXML: the contents of the sensitive elements above and of any element
+ * listed under SensitiveFields in the config. Literal occurrences of the
+ * configured username or password are only replaced inside the SOAP
+ * Header, so ticket text that happens to contain them is left alone.
+ */
+func (q Query) Redact(s string) string {
+	s = q.redactor().ReplaceAllString(s, "${1}"+redacted+"${2}")
+	return headerRe.ReplaceAllStringFunc(s, q.redactLiterals)
+}
+
+/* redactText is Redact for free text (errors, fault strings), where the username or password may appear anywhere */
+func (q Query) redactText(s string) string {
+	return q.redactLiterals(q.redactor().ReplaceAllString(s, "${1}"+redacted+"${2}"))
+}
+func (q Query) redactLiterals(s string) string {
+	for _, v := range []string{q.Password, q.UserName} {
+		if len(v) == 0 {
+			continue
+		}
+		s = strings.Replace(s, v, redacted, -1)
+		if e := xmlEscape(v); e != v {
+			s = strings.Replace(s, e, redacted, -1)
+		}
+	}
+	return s
+}
+
+/*
+ * RedactedError is an error whose message has been scrubbed; Unwrap
+ * returns the original, so errors.Is/As keep working (and so printing
+ * the unwrapped error is not redacted).
+ */
+type RedactedError struct {
+	msg string
+	err error
+}
+
+func (e *RedactedError) Error() string {
+	return e.msg
+}
+func (e *RedactedError) Unwrap() error {
+	return e.err
+}
+func (q Query) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*RedactedError); ok {
+		return err
+	}
+	return &RedactedError{msg: q.redactText(err.Error()), err: err}
+}
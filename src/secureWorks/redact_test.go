@@ -0,0 +1,57 @@
+package secureWorks
+
+import "errors"
+import "io/fs"
+import "strings"
+import "testing"
+
+func TestRedact(t *testing.T) {
+	q := Query{UserName: "jdoe", Password: "s3cr<t", SensitiveFields: []string{"clientId"}}
+	for _, c := range []struct{ in, want string }{
+		{`<ser:getQueueCount><userName>jdoe</userName><password>s3cr&lt;t</password></ser:getQueueCount>`,
+			`<ser:getQueueCount><userName>********</userName><password>********</password></ser:getQueueCount>`},
+		{`<wsse:Username>jdoe</wsse:Username><wsse:Nonce EncodingType="x">abc=</wsse:Nonce>`,
+			`<wsse:Username>********</wsse:Username><wsse:Nonce EncodingType="x">********</wsse:Nonce>`},
+		{`<clientId>1234</clientId><clientIdentifier>1</clientIdentifier>`,
+			`<clientId>********</clientId><clientIdentifier>1</clientIdentifier>`},
+		/* literals only inside the SOAP Header */
+		{`<soapenv:Header><x>jdoe</x></soapenv:Header><soapenv:Body><worklog>called jdoe</worklog></soapenv:Body>`,
+			`<soapenv:Header><x>********</x></soapenv:Header><soapenv:Body><worklog>called jdoe</worklog></soapenv:Body>`},
+		{`<soapenv:Header/><worklog>jdoe</worklog>`, `<soapenv:Header/><worklog>jdoe</worklog>`},
+	} {
+		if got := q.Redact(c.in); got != c.want {
+			t.Errorf("Redact(%q)\n got %q\nwant %q", c.in, got, c.want)
+		}
+	}
+}
+func TestRedactCachesPerFieldSet(t *testing.T) {
+	a := Query{SensitiveFields: []string{"a"}}
+	b := Query{SensitiveFields: []string{"b"}}
+	if a.redactor() != a.redactor() {
+		t.Error("redactor not cached")
+	}
+	if got := b.Redact(`<a>1</a><b>2</b>`); got != `<a>1</a><b>********</b>` {
+		t.Errorf("field sets share a pattern: %q", got)
+	}
+}
+func TestRedactError(t *testing.T) {
+	q := Query{UserName: "jdoe", Password: "hunter2"}
+	if q.redactError(nil) != nil {
+		t.Error("nil error not nil")
+	}
+	orig := &fs.PathError{Op: "open", Path: "/home/jdoe/hunter2", Err: fs.ErrNotExist}
+	err := q.redactError(orig)
+	if strings.Contains(err.Error(), "jdoe") || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("not redacted: %v", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("errors.Is does not reach the original")
+	}
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe != orig {
+		t.Error("errors.As does not reach the original")
+	}
+	if q.redactError(err) != err {
+		t.Error("redacted twice")
+	}
+}
@@ -0,0 +1,194 @@
+package secureWorks
+
+import "bytes"
+import "crypto/aes"
+import "crypto/cipher"
+import "crypto/pbkdf2"
+import "crypto/rand"
+import "crypto/sha256"
+import "encoding/base64"
+import "errors"
+import "fmt"
+import "os"
+import "os/exec"
+import "strings"
+
+/*
+ * Instead of Password the config may name one other source for it:
+ *
+ *   PasswordEnv        environment variable holding the password
+ *   PasswordFile       file holding the password, mode 0600 or stricter
+ *   PasswordCommand    shell command printing the password (vault CLI)
+ *   EncryptedPassword  output of EncryptPassword, unlocked with the
+ *                      passphrase in $SECUREWORKS_PASSPHRASE or from
+ *                      PassphraseFunc
+ */
+const PassphraseEnv = "SECUREWORKS_PASSPHRASE"
+const encryptedPrefix = "aesgcm:"
+const pbkdf2Iterations = 600000
+
+/*
+ * Asked for the EncryptedPassword passphrase when PassphraseEnv is unset.
+ * The CLIs set it to PromptPassphrase; nil (the default for library
+ * users) makes the environment variable required.
+ */
+var PassphraseFunc func() (string, error)
+
+/* PromptPassphrase reads the passphrase from the terminal */
+func PromptPassphrase() (string, error) {
+	return ReadSecret("Passphrase: ")
+}
+
+/* ResolvePassword fills Password from the configured source; ReadConfig already does this */
+func (q *Query) ResolvePassword() error {
+	if len(q.Password) > 0 {
+		return nil
+	}
+	sources := 0
+	for _, v := range []string{q.PasswordEnv, q.PasswordFile, q.PasswordCommand, q.EncryptedPassword} {
+		if len(v) > 0 {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of PasswordEnv, PasswordFile, PasswordCommand and EncryptedPassword may be set")
+	}
+
+	switch {
+	case len(q.PasswordEnv) > 0:
+		v, ok := os.LookupEnv(q.PasswordEnv)
+		if !ok {
+			return fmt.Errorf("PasswordEnv: $%s is not set", q.PasswordEnv)
+		}
+		q.Password = v
+	case len(q.PasswordFile) > 0:
+		st, err := os.Stat(q.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("PasswordFile: %v", err)
+		}
+		if st.Mode().Perm()&0077 != 0 {
+			return fmt.Errorf("PasswordFile: %s has mode %o, must not be readable by group or others (chmod 600)",
+				q.PasswordFile, st.Mode().Perm())
+		}
+		r, err := os.ReadFile(q.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("PasswordFile: %v", err)
+		}
+		q.Password = strings.TrimRight(string(r), "\r\n")
+	case len(q.PasswordCommand) > 0:
+		var stderr bytes.Buffer
+		cmd := exec.Command("/bin/sh", "-c", q.PasswordCommand)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("PasswordCommand: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		q.Password = strings.TrimRight(string(out), "\r\n")
+	case len(q.EncryptedPassword) > 0:
+		passphrase, ok := os.LookupEnv(PassphraseEnv)
+		if !ok && PassphraseFunc != nil {
+			var err error
+			if passphrase, err = PassphraseFunc(); err != nil {
+				return fmt.Errorf("EncryptedPassword: %v", err)
+			}
+		} else if !ok {
+			return fmt.Errorf("EncryptedPassword: $%s is not set", PassphraseEnv)
+		}
+		p, err := DecryptPassword(q.EncryptedPassword, passphrase)
+		if err != nil {
+			return fmt.Errorf("EncryptedPassword: %v", err)
+		}
+		q.Password = p
+	}
+	return nil
+}
+
+/*
+ * EncryptPassword seals password with AES-256-GCM under a key derived
+ * from passphrase (PBKDF2-SHA256), for use as EncryptedPassword.
+ */
+func EncryptPassword(password string, passphrase string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nil, nonce, []byte(password), nil)
+	buf := append(append(salt, nonce...), sealed...)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+func DecryptPassword(encrypted string, passphrase string) (string, error) {
+	if !strings.HasPrefix(encrypted, encryptedPrefix) {
+		return "", errors.New("not an " + encryptedPrefix + " value")
+	}
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(buf) < 16 {
+		return "", errors.New("value too short")
+	}
+	aead, err := passphraseCipher(passphrase, buf[:16])
+	if err != nil {
+		return "", err
+	}
+	buf = buf[16:]
+	if len(buf) < aead.NonceSize() {
+		return "", errors.New("value too short")
+	}
+	p, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase or corrupted value")
+	}
+	return string(p), nil
+}
+func passphraseCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+ * ReadSecret prompts on stderr and reads a line from stdin with terminal
+ * echo turned off, for the CLIs' password and passphrase prompts.
+ */
+func ReadSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 0 || err != nil || b[0] == '\n' {
+			if len(line) == 0 && err != nil {
+				return "", err
+			}
+			break
+		}
+		line = append(line, b[0])
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
@@ -0,0 +1,89 @@
+package secureWorks
+
+import "errors"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+func TestEncryptPassword(t *testing.T) {
+	enc, err := EncryptPassword("s3cret&<>", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, "aesgcm:") || strings.Contains(enc, "s3cret") {
+		t.Errorf("EncryptPassword = %q", enc)
+	}
+	if again, _ := EncryptPassword("s3cret&<>", "correct horse"); again == enc {
+		t.Error("same ciphertext twice: salt or nonce not random")
+	}
+	if p, err := DecryptPassword(enc, "correct horse"); err != nil || p != "s3cret&<>" {
+		t.Errorf("DecryptPassword = %q, %v", p, err)
+	}
+	if _, err := DecryptPassword(enc, "wrong horse"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("wrong passphrase: %v", err)
+	}
+	for _, bad := range []string{"s3cret", "aesgcm:!!!", "aesgcm:AAAA", enc[:len(enc)-8]} {
+		if _, err := DecryptPassword(bad, "correct horse"); err == nil {
+			t.Errorf("DecryptPassword(%q): no error", bad)
+		}
+	}
+}
+func TestResolvePassword(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pw")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q := Query{PasswordFile: file}
+	if err := q.ResolvePassword(); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("group-readable PasswordFile accepted: %v", err)
+	}
+	os.Chmod(file, 0600)
+	if err := q.ResolvePassword(); err != nil || q.Password != "from-file" {
+		t.Errorf("PasswordFile: %q, %v", q.Password, err)
+	}
+
+	t.Setenv("SW_TEST_PW", "from-env")
+	q = Query{PasswordEnv: "SW_TEST_PW"}
+	if err := q.ResolvePassword(); err != nil || q.Password != "from-env" {
+		t.Errorf("PasswordEnv: %q, %v", q.Password, err)
+	}
+	q = Query{PasswordCommand: "echo from-command"}
+	if err := q.ResolvePassword(); err != nil || q.Password != "from-command" {
+		t.Errorf("PasswordCommand: %q, %v", q.Password, err)
+	}
+	q = Query{PasswordEnv: "SW_TEST_PW", PasswordFile: file}
+	if err := q.ResolvePassword(); err == nil {
+		t.Error("two sources accepted")
+	}
+}
+func TestResolveEncryptedPassword(t *testing.T) {
+	enc, err := EncryptPassword("s3cret", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() (string, error)) { PassphraseFunc = f }(PassphraseFunc)
+	t.Setenv(PassphraseEnv, "")
+	os.Unsetenv(PassphraseEnv)
+
+	PassphraseFunc = nil
+	q := Query{EncryptedPassword: enc}
+	if err := q.ResolvePassword(); err == nil || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Errorf("no passphrase source: %v", err)
+	}
+	/* without the environment variable the prompt is asked */
+	PassphraseFunc = func() (string, error) { return "pass", nil }
+	if err := q.ResolvePassword(); err != nil || q.Password != "s3cret" {
+		t.Errorf("PassphraseFunc: %q, %v", q.Password, err)
+	}
+	PassphraseFunc = func() (string, error) { return "", errors.New("no terminal") }
+	q = Query{EncryptedPassword: enc}
+	if err := q.ResolvePassword(); err == nil || !strings.Contains(err.Error(), "no terminal") {
+		t.Errorf("PassphraseFunc error: %v", err)
+	}
+	t.Setenv(PassphraseEnv, "pass")
+	if err := q.ResolvePassword(); err != nil || q.Password != "s3cret" {
+		t.Errorf("%s: %q, %v", PassphraseEnv, q.Password, err)
+	}
+}
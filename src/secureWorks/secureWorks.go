@@ -7,9 +7,10 @@ import "crypto/tls"
 import "encoding/xml"
 import "time"
 import "bufio"
-import "errors"
+import "context"
+import "io"
+import "log/slog"
 import "strconv"
-import "io/ioutil"
 
 type SOAPFaultEnvelope struct {
 	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
@@ -100,7 +101,26 @@ type Ticket struct {
 	TicketType          string    `xml:"ticketType"`
 	TicketVersion       string    `xml:"ticketVersion"`
 	WorkLogs            []WorkLog `xml:"worklogs"`
+	/* From the device inventory (see Inventory), not the API */
+	DeviceAlias string `xml:"-" json:",omitempty"`
+	DeviceIp    string `xml:"-" json:",omitempty"`
 }
+
+/*
+ * TicketTime converts a ticket or worklog date to a time.Time. The API
+ * reports epoch milliseconds; small values are taken as seconds and 0
+ * (e.g. DateClosed on an open ticket) is the zero Time.
+ */
+func TicketTime(v int64) time.Time {
+	switch {
+	case v == 0:
+		return time.Time{}
+	case v < 100000000000:
+		return time.Unix(v, 0)
+	}
+	return time.UnixMilli(v)
+}
+
 type WorkLog struct {
 	DateCreated int64  `xml:"dateCreated"`
 	Description string `xml:"description"`
@@ -112,19 +132,46 @@ type IdName struct {
 }
 type Query struct {
 	xml.Name   `xml:"Config"`
-	UserName   string `xml:"UserName"`
-	Password   string `xml:"Password"`
-	ClientId   string `xml:"ClientId"`
-	LocationId string `xml:"LocationId"`
-	ApiUri     string `xml:"ApiUri"`
+	UserName   string  `xml:"UserName"`
+	Password   string  `xml:"Password"`
+	ClientId   string  `xml:"ClientId"`
+	LocationId string  `xml:"LocationId"`
+	ApiUri     string  `xml:"ApiUri"`
+	WSSecurity bool    `xml:"WSSecurity"`
+	Retries    int     `xml:"Retries"`
+	RateLimit  float64 `xml:"RateLimit"`
+
+	PasswordEnv       string `xml:"PasswordEnv"`
+	PasswordFile      string `xml:"PasswordFile"`
+	PasswordCommand   string `xml:"PasswordCommand"`
+	EncryptedPassword string `xml:"EncryptedPassword"`
+
+	DeviceCache       string `xml:"DeviceCache"`
+	DeviceCacheMaxAge string `xml:"DeviceCacheMaxAge"`
+
+	SensitiveFields []string     `xml:"SensitiveFields>Field"`
+	Inventory       *Inventory   `xml:"-"`
+	Debug           io.Writer    `xml:"-"`
+	Logger          *slog.Logger `xml:"-"`
+	Metrics         Metrics      `xml:"-"`
+	Tracer          Tracer       `xml:"-"`
+	ctx             context.Context
+	limiter         *rateLimiter
 }
 
-func (s SOAPFaultEnvelope) printErr(function string) {
-	fmt.Printf("Server Error (%s)\n\tFaultCode: %s\n\tFaultString: %s\n",
-		function, s.Body.Fault.FaultCode, s.Body.Fault.FaultString)
-	fmt.Printf("\tFaultInfo\n\t\tFaultCode: %s\n\t\tReason: %s\n",
-		s.Body.Fault.Detail.FaultInfo.FaultCode,
-		s.Body.Fault.Detail.FaultInfo.Reason)
+/*
+ * FaultError is returned when the server answers an operation with a SOAP
+ * Fault, in place of printing it; use errors.As to get the faultInfo code.
+ */
+type FaultError struct {
+	Operation string
+	Fault     SOAPFault
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("Server Error (%s) FaultCode: %s FaultString: %s FaultInfo: FaultCode: %s Reason: %s",
+		e.Operation, e.Fault.FaultCode, e.Fault.FaultString,
+		e.Fault.Detail.FaultInfo.FaultCode, e.Fault.Detail.FaultInfo.Reason)
 }
 func (s Ticket) PrintDetails() {
 	fmt.Printf("Attachment: %s (%d)\n", s.AttachmentName, s.AttachmentId)
@@ -135,6 +182,10 @@ func (s Ticket) PrintDetails() {
 	fmt.Printf("DateModified: %d\n", s.DateModified)
 	fmt.Printf("DetailedDescription: %s\n", s.DetailedDescription)
 	fmt.Printf("Devices: %s (%d)\n", s.Devices.Name, s.Devices.Id)
+	if len(s.DeviceAlias) > 0 || len(s.DeviceIp) > 0 {
+		fmt.Printf("DeviceAlias: %s\n", s.DeviceAlias)
+		fmt.Printf("DeviceIp: %s\n", s.DeviceIp)
+	}
 	fmt.Printf("EventSource: %s\n", s.EventSource)
 	fmt.Printf("IsGlobaChild: %t\n", s.IsGlobaChild)
 	fmt.Printf("IsGlobaParent: %t\n", s.IsGlobaParent)
@@ -152,15 +203,15 @@ func (s Ticket) PrintDetails() {
 }
 func (s Ticket) PrintCsv() {
 	fmt.Printf("AttachmentName,AttachmentId,ClientName,ClientId,ContactName,ContactId,DateClosed,DateCreated,"+
-		"DateModified,DetailedDescription,DeviceName,DeviceId,EventSource,"+
+		"DateModified,DetailedDescription,DeviceName,DeviceId,DeviceAlias,DeviceIp,EventSource,"+
 		"IsGlobaChild,IsGlobaParent,LocationName,LocationId,,Reason,ResponsibleParty,"+
 		"Service,Severity,"+
 		"Status,SymptomDescription,TicketId,TicketType,TicketVersion,WorkLogs\n"+
-		"%s,%d,%s,%d,%s,%d,%d,%d,%d,%s,%s,%d,%s,%t,%t,%s,%d,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
+		"%s,%d,%s,%d,%s,%d,%d,%d,%d,%s,%s,%d,%s,%s,%s,%t,%t,%s,%d,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
 		s.AttachmentName, s.AttachmentId,
 		s.Client.Name, s.Client.Id, s.Contact.Name, s.Contact.Id,
 		s.DateClosed, s.DateCreated, s.DateModified, s.DetailedDescription,
-		s.Devices.Name, s.Devices.Id, s.EventSource, s.IsGlobaChild, s.IsGlobaParent,
+		s.Devices.Name, s.Devices.Id, s.DeviceAlias, s.DeviceIp, s.EventSource, s.IsGlobaChild, s.IsGlobaParent,
 		s.Location.Name, s.Location.Id, s.Reason, s.ResponsibleParty, s.Service,
 		s.Severity, s.Status, s.SymptomDescription, s.TicketId, s.TicketType, s.TicketVersion)
 }
@@ -173,11 +224,9 @@ func (s Ticket) PrintWorkLogs() {
 func GetContactList(q Query) (*ContactListResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getContacts>
-        <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getContacts>` + credentials(q) + `
          <clientId>` + q.ClientId + `</clientId>
          <locationId>` + q.LocationId + `</locationId>
       </ser:getContacts>
@@ -186,35 +235,31 @@ func GetContactList(q Query) (*ContactListResponseEnvelope, error) {
 `
 
 	x := new(ContactListResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getContacts", SOAPxml, &x)
 	x.RawXML = buf
 	return x, err
 }
 func GetCustomerList(q Query) (*CustomerListResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getCustomerList>
-        <userName>` + q.UserName + `</userName>
-        <password>` + q.Password + `</password>
+      <ser:getCustomerList>` + credentials(q) + `
       </ser:getCustomerList>
    </soapenv:Body>
 </soapenv:Envelope>
 `
 	x := new(CustomerListResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getCustomerList", SOAPxml, &x)
 	x.RawXML = buf
 	return x, err
 }
 func GetAttachment(q Query, ticketId string, attachmentId string) (*AttachmentResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getAttachment>
-	 <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getAttachment>` + credentials(q) + `
          <ticketId>` + ticketId + `</ticketId>
          <attachmentId>` + attachmentId + `</attachmentId>
       </ser:getAttachment>
@@ -222,57 +267,59 @@ func GetAttachment(q Query, ticketId string, attachmentId string) (*AttachmentRe
 </soapenv:Envelope>
 `
 	x := new(AttachmentResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getAttachment", SOAPxml, &x, "ticket.id", ticketId)
 	x.RawXML = buf
 	return x, err
 }
 func GetTicketDetail(q Query, ticketId string) (*TicketDetailResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getTicketDetail>
-         <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getTicketDetail>` + credentials(q) + `
          <ticketId>` + ticketId + `</ticketId>
       </ser:getTicketDetail>
    </soapenv:Body>
 </soapenv:Envelope>
 `
 	x := new(TicketDetailResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getTicketDetail", SOAPxml, &x, "ticket.id", ticketId)
 	x.RawXML = buf
+	if err == nil {
+		l := []Ticket{x.Detail}
+		q.enrich(l)
+		x.Detail = l[0]
+	}
 	return x, err
 }
 func GetUpdates(q Query, ticketType string, worklogs string, limit int, assignedToCustomer int) (*UpdatesResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getUpdates>
-        <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getUpdates>` + credentials(q) + `
          <ticketType>` + ticketType + `</ticketType>
          <limit>` + strconv.Itoa(limit) + `</limit>
          <worklogs>` + worklogs + `</worklogs>
-         <assignedToCustomer>` + assignedToCustomer + `</assignedToCustomer>
+         <assignedToCustomer>` + strconv.Itoa(assignedToCustomer) + `</assignedToCustomer>
       </ser:getUpdates>
    </soapenv:Body>
 </soapenv:Envelope>
 `
 	x := new(UpdatesResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getUpdates", SOAPxml, &x)
 	x.RawXML = buf
+	if err == nil {
+		q.enrich(x.Tickets)
+	}
 	return x, err
 }
 func GetQueueTicketIds(q Query, ticketType string, limit int) (*QueueTicketIdsResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getQueueTicketIds>
-         <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getQueueTicketIds>` + credentials(q) + `
          <ticketType>` + ticketType + `</ticketType>
          <limit>` + strconv.Itoa(limit) + `</limit>
       </ser:getQueueTicketIds>
@@ -280,36 +327,32 @@ func GetQueueTicketIds(q Query, ticketType string, limit int) (*QueueTicketIdsRe
 </soapenv:Envelope>
 `
 	x := new(QueueTicketIdsResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getQueueTicketIds", SOAPxml, &x)
 	x.RawXML = buf
 	return x, err
 }
 func GetQueueCount(q Query, ticketType string) (*QueueCountResponseEnvelope, error) {
 	SOAPxml := `
 <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-   <soapenv:Header/>
+   ` + soapHeader(q) + `
    <soapenv:Body>
-      <ser:getQueueCount>
-         <userName>` + q.UserName + `</userName>
-         <password>` + q.Password + `</password>
+      <ser:getQueueCount>` + credentials(q) + `
          <ticketType>` + ticketType + `</ticketType>
       </ser:getQueueCount>
    </soapenv:Body>
 </soapenv:Envelope>
 `
 	x := new(QueueCountResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getQueueCount", SOAPxml, &x)
 	x.RawXML = buf
 	return x, err
 }
 func GetDeviceList(q Query) (*DeviceListResponseEnvelope, error) {
 	SOAPxml := `
            <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ser="http://service.ticket.api.mod.secureworks.com/">
-              <soapenv:Header/>
+              ` + soapHeader(q) + `
               <soapenv:Body>
-                 <ser:getDeviceList>
-                    <userName>` + q.UserName + `</userName>
-                    <password>` + q.Password + `</password>
+                 <ser:getDeviceList>` + credentials(q) + `
                     <clientId>` + q.ClientId + `</clientId>
                     <locationId>` + q.LocationId + `</locationId>
                  </ser:getDeviceList>
@@ -317,24 +360,72 @@ func GetDeviceList(q Query) (*DeviceListResponseEnvelope, error) {
            </soapenv:Envelope>
 	   `
 	x := new(DeviceListResponseEnvelope)
-	buf, err := makeSOAPrequest(q, SOAPxml, &x)
+	buf, err := makeSOAPrequest(q, "getDeviceList", SOAPxml, &x)
 	x.RawXML = buf
 	return x, err
 }
-func makeSOAPrequest(q Query, SOAPxml string, v interface{}) (string, error) {
-	var buf string
+
+/* attrs are key, value pairs added to the operation's trace span */
+func makeSOAPrequest(q Query, operation string, SOAPxml string, v interface{}, attrs ...string) (buf string, err error) {
+	var resp *http.Response
+	log := q.logger().With("operation", operation)
+	m := q.metrics()
+	ctx, span := q.startSpan(operation)
+	defer func() { span.End(err) }()
+	span.SetAttribute("soap.operation", operation)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		span.SetAttribute(attrs[i], attrs[i+1])
+	}
 	/* Set Insecure */
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
 	client := &http.Client{Transport: tr, Timeout: time.Second * 300}
 
-	/* Make SOAP Request */
-	resp, err := client.Post(q.ApiUri,
-		"Content-Type: text/xml;charset=UTF-8", strings.NewReader(SOAPxml))
+	if q.Debug != nil {
+		fmt.Fprintf(q.Debug, ">>> POST %s\n%s\n", q.ApiUri, q.Redact(SOAPxml))
+	}
+
+	/* Make SOAP Request, retrying transport errors and gateway failures */
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			m.ObserveRetry(operation)
+			log.Debug("soap request retry", "attempt", attempt)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second * time.Duration(attempt)):
+			}
+		}
+		if err = q.limiter.wait(ctx); err != nil {
+			break
+		}
+		log.Debug("soap request", "uri", q.ApiUri)
+		req, rerr := http.NewRequestWithContext(ctx, "POST", q.ApiUri, strings.NewReader(SOAPxml))
+		if rerr != nil {
+			err = rerr
+			break
+		}
+		req.Header.Set("Content-Type", "text/xml;charset=UTF-8")
+		if tp := span.TraceParent(); len(tp) > 0 {
+			req.Header.Set("traceparent", tp)
+		}
+		resp, err = client.Do(req)
+		if attempt >= q.Retries {
+			break
+		}
+		if err == nil && resp.StatusCode < 502 {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
 	if err != nil {
-		fmt.Printf("Error: %q\n", err)
-		return buf, errors.New("error")
+		err = q.redactError(err)
+		log.Error("soap request failed", "duration", time.Since(start), "error", err)
+		m.ObserveRequest(operation, time.Since(start), 0, 0, err)
+		return buf, err
 	}
 
 	/* Read body, store contents in "buf" */
@@ -344,31 +435,56 @@ func makeSOAPrequest(q Query, SOAPxml string, v interface{}) (string, error) {
 		buf += scanner.Text()
 		buf += " "
 	}
+	resp.Body.Close()
+	if q.Debug != nil {
+		fmt.Fprintf(q.Debug, "<<< %s\n%s\n", resp.Status, q.Redact(buf))
+	}
+	duration := time.Since(start)
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	log = log.With("duration", duration, "status", resp.StatusCode, "bytes", len(buf))
 	if err := scanner.Err(); err != nil {
-		fmt.Printf("Error: %q\n", err)
-		return buf, errors.New("error")
+		err = q.redactError(err)
+		log.Error("soap response read failed", "error", err)
+		m.ObserveRequest(operation, duration, resp.StatusCode, len(buf), err)
+		return q.Redact(buf), err
+	}
+
+	/* A SOAP Fault unmarshals cleanly into v{}, so look for one first */
+	var fault *FaultError
+	f := SOAPFaultEnvelope{}
+	if xml.Unmarshal([]byte(buf), &f) == nil && f.Body != nil && f.Body.Fault != nil {
+		fault = &FaultError{Operation: operation, Fault: *f.Body.Fault}
+		fault.Fault.FaultString = q.redactText(fault.Fault.FaultString)
+		fault.Fault.Detail.FaultInfo.Reason = q.redactText(fault.Fault.Detail.FaultInfo.Reason)
+		log.Warn("soap fault",
+			"faultcode", f.Body.Fault.FaultCode,
+			"faultstring", q.redactText(f.Body.Fault.FaultString),
+			"faultinfo", f.Body.Fault.Detail.FaultInfo.FaultCode,
+			"reason", q.redactText(f.Body.Fault.Detail.FaultInfo.Reason))
+		m.ObserveFault(operation, f.Body.Fault.Detail.FaultInfo.FaultCode)
+		span.SetAttribute("soap.fault_code", f.Body.Fault.Detail.FaultInfo.FaultCode)
 	}
-	resp.Body.Close()
 
 	/* Convert SOAP XML response to struct in v{} */
 	err = xml.Unmarshal([]byte(buf), v)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return buf, err
+		err = q.redactError(err)
+		m.ObserveRequest(operation, duration, resp.StatusCode, len(buf), err)
+		log.Error("soap response unmarshal failed", "error", err)
+		return q.Redact(buf), err
+	}
+	if fault != nil {
+		m.ObserveRequest(operation, duration, resp.StatusCode, len(buf), fault)
+		return q.Redact(buf), fault
 	}
+	m.ObserveRequest(operation, duration, resp.StatusCode, len(buf), nil)
 
-	return buf, nil
+	log.Info("soap response")
+	return q.Redact(buf), nil
 }
-func ReadConfig(fileName string) (Query, error) {
-	q := Query{}
-	r, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		return q, err
-	}
-	err = xml.Unmarshal(r, &q)
-	if err != nil {
-		fmt.Printf("error: %v\n", err)
-		return q, nil
+func (q Query) logger() *slog.Logger {
+	if q.Logger == nil {
+		return slog.New(slog.DiscardHandler)
 	}
-	return q, nil
+	return q.Logger
 }
@@ -0,0 +1,214 @@
+package siem
+
+import "fmt"
+import "secureWorks"
+import "strconv"
+import "strings"
+
+/*
+ * CEF (ArcSight Common Event Format) and LEEF 2.0 (QRadar Log Event
+ * Extended Format) renderings of tickets and worklog entries, meant to be
+ * carried as the MSG part of a syslog message. Ticket fields map to the
+ * standard keys where one exists:
+ *
+ *   Ticket field         CEF                           LEEF
+ *   TicketId             externalId                    externalId
+ *   Severity             header severity               sev
+ *   EventSource          cat                           cat
+ *   Devices              dvchost, deviceExternalId     resource, deviceId
+ *   DeviceIp, Alias      dvc, cs6 (DeviceAlias)        deviceIp, deviceAlias
+ *   Client               cs1 (Client), cn1 (ClientId)  client, clientId
+ *   Location             cs2 (Location)                location
+ *   DateModified         rt                            devTime
+ *   DateCreated/Closed   start/end                     created/closed
+ *
+ * Worklog events use the worklog's own date and description.
+ */
+const (
+	CEF  = "cef"
+	LEEF = "leef"
+)
+
+const (
+	vendor  = "SecureWorks"
+	product = "Ticketing"
+	version = "1.0"
+)
+
+/* Format renders t, or the worklog w of t when w is non-nil */
+func Format(format string, t secureWorks.Ticket, w *secureWorks.WorkLog) (string, error) {
+	switch strings.ToLower(format) {
+	case CEF:
+		return FormatCEF(t, w), nil
+	case LEEF:
+		return FormatLEEF(t, w), nil
+	}
+	return "", fmt.Errorf("unknown format %q (want cef or leef)", format)
+}
+
+/* Numeric severity, 0-10 as both CEF and LEEF use it; -1 if unknown */
+func Severity(s string) int {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "CRITICAL", "VERY-HIGH":
+		return 10
+	case "HIGH":
+		return 8
+	case "MEDIUM":
+		return 5
+	case "LOW":
+		return 3
+	case "INFO", "INFORMATIONAL":
+		return 1
+	}
+	return -1
+}
+
+type pair struct {
+	k string
+	v string
+}
+
+/* fields lists the mapped ticket fields in LEEF naming, skipping empty ones */
+func fields(t secureWorks.Ticket, w *secureWorks.WorkLog) []pair {
+	var l []pair
+	add := func(k string, v string) {
+		if len(v) > 0 {
+			l = append(l, pair{k, v})
+		}
+	}
+	date := func(v int64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatInt(secureWorks.TicketTime(v).UnixMilli(), 10)
+	}
+	id := func(v int) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	}
+	add("externalId", t.TicketId)
+	add("ticketVersion", t.TicketVersion)
+	add("ticketType", t.TicketType)
+	add("cat", t.EventSource)
+	add("resource", t.Devices.Name)
+	add("deviceId", id(t.Devices.Id))
+	add("deviceIp", t.DeviceIp)
+	add("deviceAlias", t.DeviceAlias)
+	add("client", t.Client.Name)
+	add("clientId", id(t.Client.Id))
+	add("location", t.Location.Name)
+	add("status", t.Status)
+	add("service", t.Service)
+	if w == nil {
+		add("devTime", date(t.DateModified))
+		add("created", date(t.DateCreated))
+		add("closed", date(t.DateClosed))
+		add("symptom", t.SymptomDescription)
+		add("msg", t.DetailedDescription)
+	} else {
+		add("devTime", date(w.DateCreated))
+		add("workLogType", w.Type)
+		add("symptom", t.SymptomDescription)
+		add("msg", w.Description)
+	}
+	return l
+}
+
+/* CEF header fields escape | and \; extension values escape = and \ */
+var cefHeader = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+var cefValue = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+/* CEF names for the fields that have a standard or labelled custom key */
+var cefKeys = map[string]string{
+	"externalId":  "externalId",
+	"cat":         "cat",
+	"resource":    "dvchost",
+	"deviceId":    "deviceExternalId",
+	"deviceIp":    "dvc",
+	"deviceAlias": "cs6",
+	"client":      "cs1",
+	"clientId":    "cn1",
+	"location":    "cs2",
+	"status":      "cs3",
+	"ticketType":  "cs4",
+	"service":     "cs5",
+	"devTime":     "rt",
+	"created":     "start",
+	"closed":      "end",
+	"msg":         "msg",
+}
+var cefLabels = map[string]string{
+	"cs1": "Client",
+	"cn1": "ClientId",
+	"cs2": "Location",
+	"cs3": "Status",
+	"cs4": "TicketType",
+	"cs5": "Service",
+	"cs6": "DeviceAlias",
+}
+
+func eventId(t secureWorks.Ticket, w *secureWorks.WorkLog) string {
+	if w != nil {
+		return "WORKLOG"
+	}
+	if len(t.TicketType) > 0 {
+		return t.TicketType
+	}
+	return "TICKET"
+}
+func eventName(t secureWorks.Ticket, w *secureWorks.WorkLog) string {
+	if w != nil {
+		return "Work log " + w.Type + " on " + t.TicketId
+	}
+	if len(t.SymptomDescription) > 0 {
+		return t.SymptomDescription
+	}
+	return "Ticket " + t.TicketId
+}
+
+func FormatCEF(t secureWorks.Ticket, w *secureWorks.WorkLog) string {
+	sev := "Unknown"
+	if n := Severity(t.Severity); n >= 0 {
+		sev = strconv.Itoa(n)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%s|", vendor, product, version,
+		cefHeader.Replace(eventId(t, w)), cefHeader.Replace(eventName(t, w)), sev)
+	n := 0
+	for _, p := range fields(t, w) {
+		k, ok := cefKeys[p.k]
+		if !ok {
+			continue
+		}
+		if n > 0 {
+			b.WriteByte(' ')
+		}
+		n++
+		if label, ok := cefLabels[k]; ok {
+			fmt.Fprintf(&b, "%sLabel=%s ", k, label)
+		}
+		fmt.Fprintf(&b, "%s=%s", k, cefValue.Replace(p.v))
+	}
+	return b.String()
+}
+
+/* LEEF has no escaping: the ^ delimiter and line breaks become spaces */
+var leefValue = strings.NewReplacer("^", " ", "\r", " ", "\n", " ", "\t", " ")
+
+func FormatLEEF(t secureWorks.Ticket, w *secureWorks.WorkLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|^|", vendor, product, version, leefValue.Replace(eventId(t, w)))
+	l := fields(t, w)
+	if n := Severity(t.Severity); n >= 0 {
+		l = append([]pair{{"sev", strconv.Itoa(n)}}, l...)
+	}
+	for i, p := range l {
+		if i > 0 {
+			b.WriteByte('^')
+		}
+		fmt.Fprintf(&b, "%s=%s", p.k, leefValue.Replace(p.v))
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package siem
+
+import "bufio"
+import "context"
+import "io"
+import "net"
+import "secureWorks"
+import "strings"
+import "testing"
+import "time"
+
+var ticket = secureWorks.Ticket{
+	TicketId:            "INC-1",
+	TicketType:          "INCIDENT",
+	Severity:            "High",
+	EventSource:         "IDS",
+	SymptomDescription:  "Beacon | to c2",
+	DetailedDescription: "a=b \\ c\nnext line ^ caret",
+	Client:              secureWorks.IdName{Id: 7, Name: "Acme"},
+	DeviceIp:            "10.0.0.5",
+	DateModified:        1700000000000,
+}
+
+func TestFormatCEF(t *testing.T) {
+	got := FormatCEF(ticket, nil)
+	want := `CEF:0|SecureWorks|Ticketing|1.0|INCIDENT|Beacon \| to c2|8|` +
+		`externalId=INC-1 cs4Label=TicketType cs4=INCIDENT cat=IDS dvc=10.0.0.5 ` +
+		`cs1Label=Client cs1=Acme cn1Label=ClientId cn1=7 rt=1700000000000 ` +
+		`msg=a\=b \\ c\nnext line ^ caret`
+	if got != want {
+		t.Errorf("FormatCEF\n got %s\nwant %s", got, want)
+	}
+
+	w := secureWorks.WorkLog{DateCreated: 1700000001000, Type: "NOTE", Description: "called"}
+	got = FormatCEF(secureWorks.Ticket{TicketId: "INC-2"}, &w)
+	want = `CEF:0|SecureWorks|Ticketing|1.0|WORKLOG|Work log NOTE on INC-2|Unknown|externalId=INC-2 rt=1700000001000 msg=called`
+	if got != want {
+		t.Errorf("FormatCEF worklog\n got %s\nwant %s", got, want)
+	}
+}
+func TestFormatLEEF(t *testing.T) {
+	got := FormatLEEF(ticket, nil)
+	want := `LEEF:2.0|SecureWorks|Ticketing|1.0|INCIDENT|^|sev=8^externalId=INC-1^ticketType=INCIDENT^cat=IDS^` +
+		`deviceIp=10.0.0.5^client=Acme^clientId=7^devTime=1700000000000^symptom=Beacon | to c2^` +
+		`msg=a=b \ c next line   caret`
+	if got != want {
+		t.Errorf("FormatLEEF\n got %s\nwant %s", got, want)
+	}
+	if _, err := Format("json", ticket, nil); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+func TestSeverity(t *testing.T) {
+	for in, want := range map[string][2]int{
+		"CRITICAL": {10, 2}, " high ": {8, 3}, "Medium": {5, 4}, "low": {3, 5}, "INFO": {1, 6}, "": {-1, 6},
+	} {
+		if got := Severity(in); got != want[0] {
+			t.Errorf("Severity(%q) = %d, want %d", in, got, want[0])
+		}
+		if got := SyslogSeverity(in); got != want[1] {
+			t.Errorf("SyslogSeverity(%q) = %d, want %d", in, got, want[1])
+		}
+	}
+}
+func TestMessage(t *testing.T) {
+	s := &Syslog{Facility: 20, Hostname: "soc host", AppName: "swé"}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("x", 3600))
+	got := string(s.Message(3, ts, "", "msg with spaces"))
+	prefix := "<163>1 2024-01-02T02:04:05.600Z sochost sw "
+	if !strings.HasPrefix(got, prefix) || !strings.HasSuffix(got, " - - msg with spaces") {
+		t.Errorf("Message = %q", got)
+	}
+}
+func TestWriteOctetCounted(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	got := make(chan string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer c.Close()
+		b, _ := io.ReadAll(bufio.NewReader(c))
+		got <- string(b)
+	}()
+	s, err := NewSyslog("tcp://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"one", "two\nlines"} {
+		if err := s.Write(context.Background(), []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	if g := <-got; g != "3 one9 two\nlines" {
+		t.Errorf("stream = %q", g)
+	}
+}
+func TestNewSyslog(t *testing.T) {
+	for uri, want := range map[string]string{
+		"udp://siem":       "siem:514",
+		"tls://siem":       "siem:6514",
+		"tcp://siem:10514": "siem:10514",
+	} {
+		s, err := NewSyslog(uri)
+		if err != nil || s.Address != want {
+			t.Errorf("NewSyslog(%q) = %v, %v", uri, s, err)
+		}
+	}
+	for _, uri := range []string{"http://siem", "udp://"} {
+		if _, err := NewSyslog(uri); err == nil {
+			t.Errorf("NewSyslog(%q): no error", uri)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package siem
+
+import "context"
+import "crypto/tls"
+import "fmt"
+import "net"
+import "net/url"
+import "os"
+import "secureWorks"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+/*
+ * Syslog sends RFC 5424 messages to a collector. Network is "udp" (one
+ * message per datagram, RFC 5426), "tcp" or "tls" (octet-counted
+ * framing, RFC 6587/5425). The connection is opened on first use and
+ * reopened once if a write fails.
+ */
+type Syslog struct {
+	Network   string
+	Address   string
+	TLSConfig *tls.Config
+	/* Facility code, default 1 (user) */
+	Facility int
+	/* APP-NAME and HOSTNAME, default "secureworks" and os.Hostname() */
+	AppName  string
+	Hostname string
+	Timeout  time.Duration
+	mu       sync.Mutex
+	conn     net.Conn
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+/* Facility parses a facility name ("local0") or number */
+func Facility(s string) (int, error) {
+	if n, ok := facilities[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 23 {
+		return 0, fmt.Errorf("unknown syslog facility %q", s)
+	}
+	return n, nil
+}
+
+/* NewSyslog takes a collector URI: udp://host:514, tcp://host:601, tls://host:6514 */
+func NewSyslog(uri string) (*Syslog, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	s := &Syslog{Network: strings.ToLower(u.Scheme), Address: u.Host, Facility: 1}
+	port := map[string]string{"udp": "514", "tcp": "601", "tls": "6514"}[s.Network]
+	if len(port) == 0 {
+		return nil, fmt.Errorf("%s: scheme must be udp, tcp or tls", uri)
+	}
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("%s: missing host", uri)
+	}
+	if len(u.Port()) == 0 {
+		s.Address = net.JoinHostPort(u.Hostname(), port)
+	}
+	return s, nil
+}
+
+/* SyslogSeverity maps a ticket Severity to a syslog severity (0-7) */
+func SyslogSeverity(s string) int {
+	switch n := Severity(s); {
+	case n >= 10:
+		return 2 /* crit */
+	case n >= 8:
+		return 3 /* err */
+	case n >= 5:
+		return 4 /* warning */
+	case n >= 2:
+		return 5 /* notice */
+	}
+	return 6 /* info */
+}
+
+/*
+ * Message builds an RFC 5424 message:
+ *   <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+ */
+func (s *Syslog) Message(severity int, ts time.Time, msgId string, msg string) []byte {
+	host := s.Hostname
+	if len(host) == 0 {
+		host, _ = os.Hostname()
+	}
+	app := s.AppName
+	if len(app) == 0 {
+		app = "secureworks"
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d %s - %s", s.Facility*8+severity,
+		ts.UTC().Format("2006-01-02T15:04:05.000Z"), header(host), header(app),
+		os.Getpid(), header(msgId), msg))
+}
+
+/* Header fields are printable ASCII without spaces; "-" when empty */
+func header(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
+func (s *Syslog) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: s.timeout()}
+	if s.Network == "tls" {
+		c := s.TLSConfig
+		if c == nil {
+			c = &tls.Config{}
+		}
+		td := &tls.Dialer{NetDialer: d, Config: c}
+		return td.DialContext(ctx, "tcp", s.Address)
+	}
+	return d.DialContext(ctx, s.Network, s.Address)
+}
+func (s *Syslog) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return 30 * time.Second
+}
+
+/* Write sends one message, framed for the transport */
+func (s *Syslog) Write(ctx context.Context, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame := msg
+	if s.Network != "udp" {
+		frame = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	var err error
+	for try := 0; try < 2; try++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(ctx); err != nil {
+				return err
+			}
+		}
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(s.timeout())
+		}
+		s.conn.SetWriteDeadline(deadline)
+		if _, err = s.conn.Write(frame); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+/* SendTicket sends t in format, then each of worklogs as its own message */
+func (s *Syslog) SendTicket(ctx context.Context, format string, t secureWorks.Ticket, worklogs []secureWorks.WorkLog) error {
+	msg, err := Format(format, t, nil)
+	if err != nil {
+		return err
+	}
+	sev := SyslogSeverity(t.Severity)
+	if err := s.Write(ctx, s.Message(sev, secureWorks.TicketTime(t.DateModified), "ticket", msg)); err != nil {
+		return err
+	}
+	for i := range worklogs {
+		w := worklogs[i]
+		msg, _ := Format(format, t, &w)
+		if err := s.Write(ctx, s.Message(sev, secureWorks.TicketTime(w.DateCreated), "worklog", msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
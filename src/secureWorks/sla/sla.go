@@ -0,0 +1,259 @@
+package sla
+
+import "math"
+import "secureWorks"
+import "sort"
+import "strings"
+import "time"
+
+/*
+ * Response-time analytics over archived tickets. For each ticket:
+ *
+ *   first response  DateCreated to the earliest worklog counting as a
+ *                   response (see Thresholds.Responds)
+ *   time to close   DateCreated to DateClosed
+ *   reopens         closed -> open transitions between archived versions
+ *
+ * A ticket closed and reopened between two syncs leaves no closed version
+ * behind, so reopens are a lower bound.
+ */
+type Measure struct {
+	TicketId         string    `json:"ticketId"`
+	Severity         string    `json:"severity"`
+	Client           string    `json:"client"`
+	Service          string    `json:"service"`
+	ResponsibleParty string    `json:"responsibleParty"`
+	Status           string    `json:"status"`
+	Created          time.Time `json:"created"`
+	Responded        bool      `json:"responded"`
+	FirstResponse    Duration  `json:"firstResponse,omitempty"`
+	Closed           bool      `json:"closed"`
+	TimeToClose      Duration  `json:"timeToClose,omitempty"`
+	Reopens          int       `json:"reopens"`
+}
+
+/* Duration marshals as a Go duration string ("1h30m0s") */
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+/*
+ * closed goes by Status, which a reopened ticket may show next to the
+ * DateClosed it kept; DateClosed only decides when there is no Status.
+ */
+func closed(t secureWorks.Ticket) bool {
+	if len(t.Status) == 0 {
+		return t.DateClosed != 0
+	}
+	switch strings.ToUpper(t.Status) {
+	case "CLOSED", "RESOLVED", "CANCELLED":
+		return true
+	}
+	return false
+}
+
+/*
+ * Compute measures a ticket from its archived versions, oldest first.
+ * th selects the worklogs counting as a response (nil: all of them).
+ */
+func Compute(versions []secureWorks.Ticket, th *Thresholds) Measure {
+	t := versions[len(versions)-1]
+	m := Measure{TicketId: t.TicketId, Severity: strings.ToUpper(t.Severity), Client: t.Client.Name,
+		Service: t.Service, ResponsibleParty: t.ResponsibleParty, Status: t.Status,
+		Created: secureWorks.TicketTime(t.DateCreated)}
+	for i := 1; i < len(versions); i++ {
+		if closed(versions[i-1]) && !closed(versions[i]) {
+			m.Reopens++
+		}
+	}
+	if m.Created.IsZero() {
+		return m
+	}
+	var first time.Time
+	for _, w := range t.WorkLogs {
+		c := secureWorks.TicketTime(w.DateCreated)
+		if c.IsZero() || c.Before(m.Created) || !th.Responds(w) {
+			continue
+		}
+		if first.IsZero() || c.Before(first) {
+			first = c
+		}
+	}
+	if !first.IsZero() {
+		m.Responded = true
+		m.FirstResponse = Duration(first.Sub(m.Created))
+	}
+	if c := secureWorks.TicketTime(t.DateClosed); !c.IsZero() && closed(t) && !c.Before(m.Created) {
+		m.Closed = true
+		m.TimeToClose = Duration(c.Sub(m.Created))
+	}
+	return m
+}
+
+/* Stats summarises durations; percentiles are nearest-rank */
+type Stats struct {
+	Count int      `json:"count"`
+	Mean  Duration `json:"mean"`
+	P50   Duration `json:"p50"`
+	P90   Duration `json:"p90"`
+	P95   Duration `json:"p95"`
+	P99   Duration `json:"p99"`
+	Max   Duration `json:"max"`
+}
+
+func stats(l []time.Duration) Stats {
+	s := Stats{Count: len(l)}
+	if len(l) == 0 {
+		return s
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	var sum time.Duration
+	for _, d := range l {
+		sum += d
+	}
+	rank := func(p float64) Duration {
+		return Duration(l[int(math.Ceil(p/100*float64(len(l))))-1])
+	}
+	s.Mean = Duration(sum / time.Duration(len(l)))
+	s.P50, s.P90, s.P95, s.P99 = rank(50), rank(90), rank(95), rank(99)
+	s.Max = Duration(l[len(l)-1])
+	return s
+}
+
+/* Breakdown dimensions */
+const (
+	BySeverity    = "severity"
+	ByClient      = "client"
+	ByService     = "service"
+	ByResponsible = "responsible"
+)
+
+var Dimensions = []string{BySeverity, ByClient, ByService, ByResponsible}
+
+func (m Measure) key(dimension string) string {
+	var k string
+	switch dimension {
+	case BySeverity:
+		k = m.Severity
+	case ByClient:
+		k = m.Client
+	case ByService:
+		k = m.Service
+	case ByResponsible:
+		k = m.ResponsibleParty
+	}
+	if len(k) == 0 {
+		return "(none)"
+	}
+	return k
+}
+
+type Group struct {
+	Key           string `json:"key"`
+	Tickets       int    `json:"tickets"`
+	FirstResponse Stats  `json:"firstResponse"`
+	TimeToClose   Stats  `json:"timeToClose"`
+	Reopens       int    `json:"reopens"`
+	Reopened      int    `json:"reopened"`
+	Breaches      int    `json:"breaches"`
+}
+
+/* Breach is a ticket over a threshold; Open means it is still running (no response or not closed yet) */
+type Breach struct {
+	TicketId string   `json:"ticketId"`
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	Client   string   `json:"client"`
+	Limit    Duration `json:"limit"`
+	Actual   Duration `json:"actual"`
+	Open     bool     `json:"open"`
+	over     time.Duration
+}
+
+type Report struct {
+	Generated time.Time          `json:"generated"`
+	Overall   Group              `json:"overall"`
+	By        map[string][]Group `json:"by"`
+	Breaches  []Breach           `json:"breaches"`
+}
+
+/* breaches checks m against th at time now */
+func breaches(m Measure, th *Thresholds, now time.Time) []Breach {
+	var l []Breach
+	check := func(kind string, done bool, took Duration) {
+		limit, ok := th.Limit(kind, m.Severity)
+		if !ok || m.Created.IsZero() {
+			return
+		}
+		actual, open := time.Duration(took), false
+		if !done {
+			/* A ticket closed without a worklog will never get a first response */
+			if m.Closed {
+				return
+			}
+			actual, open = now.Sub(m.Created).Round(time.Second), true
+		}
+		if actual > limit {
+			l = append(l, Breach{TicketId: m.TicketId, Kind: kind, Severity: m.Severity, Client: m.Client,
+				Limit: Duration(limit), Actual: Duration(actual), Open: open, over: actual - limit})
+		}
+	}
+	check(FirstResponse, m.Responded, m.FirstResponse)
+	check(TimeToClose, m.Closed, m.TimeToClose)
+	return l
+}
+
+func group(key string, ms []Measure, breached map[string]int) Group {
+	g := Group{Key: key, Tickets: len(ms)}
+	var first, close []time.Duration
+	for _, m := range ms {
+		if m.Responded {
+			first = append(first, time.Duration(m.FirstResponse))
+		}
+		if m.Closed {
+			close = append(close, time.Duration(m.TimeToClose))
+		}
+		g.Reopens += m.Reopens
+		if m.Reopens > 0 {
+			g.Reopened++
+		}
+		g.Breaches += breached[m.TicketId]
+	}
+	g.FirstResponse, g.TimeToClose = stats(first), stats(close)
+	return g
+}
+
+/*
+ * Build aggregates ms overall and per dimension (groups sorted by key)
+ * and lists the breaches of th (nil: none), worst overrun first. Open
+ * tickets count against a threshold from creation until now.
+ */
+func Build(ms []Measure, th *Thresholds, dimensions []string, now time.Time) Report {
+	r := Report{Generated: now, By: map[string][]Group{}, Breaches: []Breach{}}
+	breached := map[string]int{}
+	for _, m := range ms {
+		b := breaches(m, th, now)
+		breached[m.TicketId] += len(b)
+		r.Breaches = append(r.Breaches, b...)
+	}
+	sort.SliceStable(r.Breaches, func(i, j int) bool { return r.Breaches[i].over > r.Breaches[j].over })
+	r.Overall = group("all", ms, breached)
+	for _, d := range dimensions {
+		keyed := map[string][]Measure{}
+		for _, m := range ms {
+			keyed[m.key(d)] = append(keyed[m.key(d)], m)
+		}
+		var l []Group
+		for k, v := range keyed {
+			l = append(l, group(k, v, breached))
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i].Key < l[j].Key })
+		r.By[d] = l
+	}
+	return r
+}
@@ -0,0 +1,156 @@
+package sla
+
+import "os"
+import "path/filepath"
+import "reflect"
+import "secureWorks"
+import "testing"
+import "time"
+
+func loadThresholds(t *testing.T, js string) *Thresholds {
+	file := filepath.Join(t.TempDir(), "sla.json")
+	if err := os.WriteFile(file, []byte(js), 0600); err != nil {
+		t.Fatal(err)
+	}
+	th, err := LoadThresholds(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return th
+}
+func TestStats(t *testing.T) {
+	d := func(l ...int) []time.Duration {
+		var r []time.Duration
+		for _, n := range l {
+			r = append(r, time.Duration(n)*time.Minute)
+		}
+		return r
+	}
+	m := func(n int) Duration {
+		return Duration(time.Duration(n) * time.Minute)
+	}
+	for _, c := range []struct {
+		in   []time.Duration
+		want Stats
+	}{
+		{nil, Stats{}},
+		{d(7), Stats{Count: 1, Mean: m(7), P50: m(7), P90: m(7), P95: m(7), P99: m(7), Max: m(7)}},
+		/* rank ceil(p/100*n): p50 of 2 is the 1st, p90 the 2nd */
+		{d(4, 2), Stats{Count: 2, Mean: m(3), P50: m(2), P90: m(4), P95: m(4), P99: m(4), Max: m(4)}},
+		/* of 10, p90 is the 9th and p95/p99 the 10th */
+		{d(10, 9, 8, 7, 6, 5, 4, 3, 2, 1), Stats{Count: 10, Mean: Duration(330 * time.Second), P50: m(5), P90: m(9), P95: m(10), P99: m(10), Max: m(10)}},
+		/* of 20, p95 is the 19th */
+		{d(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20),
+			Stats{Count: 20, Mean: Duration(630 * time.Second), P50: m(10), P90: m(18), P95: m(19), P99: m(20), Max: m(20)}},
+	} {
+		if got := stats(c.in); got != c.want {
+			t.Errorf("stats(%v)\n got %+v\nwant %+v", c.in, got, c.want)
+		}
+	}
+}
+func TestClosed(t *testing.T) {
+	for _, c := range []struct {
+		status string
+		date   int64
+		want   bool
+	}{
+		{"CLOSED", 0, true},
+		{"Resolved", 5, true},
+		{"cancelled", 0, true},
+		/* reopened tickets may keep their DateClosed */
+		{"OPEN", 5, false},
+		{"IN_PROGRESS", 0, false},
+		{"", 5, true},
+		{"", 0, false},
+	} {
+		if got := closed(secureWorks.Ticket{Status: c.status, DateClosed: c.date}); got != c.want {
+			t.Errorf("closed(%q, %d) = %v, want %v", c.status, c.date, got, c.want)
+		}
+	}
+}
+func TestCompute(t *testing.T) {
+	const created = 1700000000000
+	at := func(n int64) int64 {
+		return created + n*60000
+	}
+	open := secureWorks.Ticket{TicketId: "INC-1", Severity: "high", Status: "OPEN", DateCreated: created,
+		WorkLogs: []secureWorks.WorkLog{
+			{DateCreated: at(-5), Type: "NOTE"},
+			{DateCreated: 0, Type: "NOTE"},
+			{DateCreated: at(1), Type: "AUTOMATED"},
+			{DateCreated: at(30), Type: "NOTE"},
+			{DateCreated: at(20), Type: "client_update"},
+		}}
+	done := open
+	done.Status, done.DateClosed = "CLOSED", at(90)
+	reopened := open
+	reopened.DateClosed = at(90)
+
+	for _, c := range []struct {
+		name     string
+		versions []secureWorks.Ticket
+		th       *Thresholds
+		want     Measure
+	}{
+		{"any worklog", []secureWorks.Ticket{open}, nil, Measure{Responded: true, FirstResponse: Duration(time.Minute)}},
+		{"response types", []secureWorks.Ticket{open}, loadThresholds(t, `{"responseTypes": ["NOTE", "CLIENT_UPDATE"]}`),
+			Measure{Responded: true, FirstResponse: Duration(20 * time.Minute)}},
+		{"no response", []secureWorks.Ticket{open}, loadThresholds(t, `{"responseTypes": ["EMAIL"]}`), Measure{}},
+		{"closed", []secureWorks.Ticket{open, done}, nil,
+			Measure{Status: "CLOSED", Responded: true, FirstResponse: Duration(time.Minute), Closed: true, TimeToClose: Duration(90 * time.Minute)}},
+		{"reopened", []secureWorks.Ticket{open, done, reopened, done, reopened}, nil,
+			Measure{Responded: true, FirstResponse: Duration(time.Minute), Reopens: 2}},
+	} {
+		c.want.TicketId, c.want.Severity, c.want.Created = "INC-1", "HIGH", secureWorks.TicketTime(created)
+		if len(c.want.Status) == 0 {
+			c.want.Status = "OPEN"
+		}
+		if got := Compute(c.versions, c.th); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+	if m := Compute([]secureWorks.Ticket{{TicketId: "INC-2", WorkLogs: open.WorkLogs}}, nil); m.Responded || !m.Created.IsZero() {
+		t.Errorf("ticket without DateCreated: %+v", m)
+	}
+}
+func TestBreaches(t *testing.T) {
+	th := loadThresholds(t, `{"firstResponse": {"HIGH": "15m", "default": "1h"}, "close": {"default": "4h"}}`)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(5 * time.Hour)
+	base := Measure{TicketId: "INC-1", Severity: "HIGH", Client: "Acme", Created: created}
+	with := func(f func(m *Measure)) Measure {
+		m := base
+		f(&m)
+		return m
+	}
+	breach := func(kind string, limit time.Duration, actual time.Duration, open bool) Breach {
+		return Breach{TicketId: "INC-1", Kind: kind, Severity: "HIGH", Client: "Acme",
+			Limit: Duration(limit), Actual: Duration(actual), Open: open, over: actual - limit}
+	}
+	for _, c := range []struct {
+		name string
+		m    Measure
+		th   *Thresholds
+		want []Breach
+	}{
+		{"within limits", with(func(m *Measure) {
+			m.Responded, m.FirstResponse, m.Closed, m.TimeToClose = true, Duration(10*time.Minute), true, Duration(time.Hour)
+		}), th, nil},
+		{"late", with(func(m *Measure) {
+			m.Responded, m.FirstResponse, m.Closed, m.TimeToClose = true, Duration(20*time.Minute), true, Duration(6*time.Hour)
+		}), th, []Breach{breach(FirstResponse, 15*time.Minute, 20*time.Minute, false), breach(TimeToClose, 4*time.Hour, 6*time.Hour, false)}},
+		/* still waiting: counted from creation until now */
+		{"open", base, th, []Breach{breach(FirstResponse, 15*time.Minute, 5*time.Hour, true), breach(TimeToClose, 4*time.Hour, 5*time.Hour, true)}},
+		/* closed without a response is not a response breach */
+		{"closed unanswered", with(func(m *Measure) { m.Closed, m.TimeToClose = true, Duration(time.Hour) }), th, nil},
+		{"default severity", with(func(m *Measure) {
+			m.Severity, m.Responded, m.FirstResponse, m.Closed, m.TimeToClose = "LOW", true, Duration(30*time.Minute), true, Duration(time.Hour)
+		}), th, nil},
+		{"no thresholds", base, nil, nil},
+		{"no created", Measure{TicketId: "INC-1", Severity: "HIGH"}, th, nil},
+	} {
+		if got := breaches(c.m, c.th, now); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package sla
+
+import "encoding/json"
+import "fmt"
+import "os"
+import "secureWorks"
+import "strings"
+import "time"
+
+/* Threshold kinds */
+const (
+	FirstResponse = "first-response"
+	TimeToClose   = "close"
+)
+
+/*
+ * Thresholds are the contractual limits per Severity, read from a JSON
+ * file of Go durations; "default" applies to severities not listed.
+ * responseTypes lists the worklog types that count as a first response;
+ * without it any worklog does, automated ones included:
+ *
+ *   {"firstResponse": {"CRITICAL": "15m", "HIGH": "1h", "default": "4h"},
+ *    "close":         {"CRITICAL": "4h", "default": "72h"},
+ *    "responseTypes": ["CLIENT_UPDATE", "NOTE"]}
+ */
+type Thresholds struct {
+	FirstResponse map[string]string `json:"firstResponse"`
+	Close         map[string]string `json:"close"`
+	ResponseTypes []string          `json:"responseTypes"`
+	limits        map[string]map[string]time.Duration
+}
+
+func LoadThresholds(fileName string) (*Thresholds, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	th := &Thresholds{}
+	if err := json.Unmarshal(b, th); err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	th.limits = map[string]map[string]time.Duration{}
+	for kind, m := range map[string]map[string]string{FirstResponse: th.FirstResponse, TimeToClose: th.Close} {
+		th.limits[kind] = map[string]time.Duration{}
+		for sev, v := range m {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s: %s %s: %q is not a duration like 4h", fileName, kind, sev, v)
+			}
+			th.limits[kind][strings.ToUpper(sev)] = d
+		}
+	}
+	return th, nil
+}
+
+/* Limit returns the kind's limit for severity; false if none applies (or th is nil) */
+func (th *Thresholds) Limit(kind string, severity string) (time.Duration, bool) {
+	if th == nil {
+		return 0, false
+	}
+	if d, ok := th.limits[kind][strings.ToUpper(severity)]; ok {
+		return d, true
+	}
+	d, ok := th.limits[kind]["DEFAULT"]
+	return d, ok
+}
+
+/* Responds tells whether w counts as a response: any worklog unless ResponseTypes are set (or th is nil) */
+func (th *Thresholds) Responds(w secureWorks.WorkLog) bool {
+	if th == nil || len(th.ResponseTypes) == 0 {
+		return true
+	}
+	for _, t := range th.ResponseTypes {
+		if strings.EqualFold(t, w.Type) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package snapshot
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "io"
+import "net/url"
+import "os"
+import "path/filepath"
+import "secureWorks"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+
+/*
+ * Store keeps snapshots of the device list, one JSON file per snapshot
+ * in a directory per ClientId/LocationId:
+ *
+ *   <dir>/<ClientId>-<LocationId>/<20060102T150405Z>.json
+ *
+ * Take only writes a snapshot when the list differs from the previous
+ * one, so every file marks a change and the diff between neighbouring
+ * files is the change report.
+ */
+type Store struct {
+	Dir string
+}
+
+type Snapshot struct {
+	ClientId   string                   `json:"clientId"`
+	LocationId string                   `json:"locationId"`
+	Taken      time.Time                `json:"taken"`
+	Devices    []secureWorks.DeviceList `json:"devices"`
+}
+
+/* Report is the change between two snapshots */
+type Report struct {
+	ClientId   string                     `json:"clientId"`
+	LocationId string                     `json:"locationId"`
+	From       time.Time                  `json:"from"`
+	To         time.Time                  `json:"to"`
+	Changes    []secureWorks.DeviceChange `json:"changes"`
+}
+
+const stamp = "20060102T150405Z"
+
+var ErrNotFound = errors.New("no device snapshot")
+
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+func (s *Store) scopeDir(clientId string, locationId string) string {
+	return filepath.Join(s.Dir, url.PathEscape(clientId)+"-"+url.PathEscape(locationId))
+}
+
+/* Save writes devices as a snapshot taken now */
+func (s *Store) Save(clientId string, locationId string, devices []secureWorks.DeviceList) (Snapshot, error) {
+	n := Snapshot{ClientId: clientId, LocationId: locationId, Taken: time.Now().UTC().Truncate(time.Second), Devices: devices}
+	sort.Slice(n.Devices, func(i, j int) bool { return n.Devices[i].DeviceId < n.Devices[j].DeviceId })
+	dir := s.scopeDir(clientId, locationId)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return n, err
+	}
+	b, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return n, err
+	}
+	fileName := filepath.Join(dir, n.Taken.Format(stamp)+".json")
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp, fileName)
+}
+
+/* Times lists when the scope's snapshots were taken, oldest first */
+func (s *Store) Times(clientId string, locationId string) ([]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(s.scopeDir(clientId, locationId), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var l []time.Time
+	for _, f := range files {
+		if t, err := time.Parse(stamp, strings.TrimSuffix(filepath.Base(f), ".json")); err == nil {
+			l = append(l, t)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Before(l[j]) })
+	return l, nil
+}
+func (s *Store) Load(clientId string, locationId string, taken time.Time) (Snapshot, error) {
+	var n Snapshot
+	b, err := os.ReadFile(filepath.Join(s.scopeDir(clientId, locationId), taken.UTC().Format(stamp)+".json"))
+	if os.IsNotExist(err) {
+		return n, ErrNotFound
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, json.Unmarshal(b, &n)
+}
+
+/* At returns the last snapshot taken at or before t */
+func (s *Store) At(clientId string, locationId string, t time.Time) (Snapshot, error) {
+	l, err := s.Times(clientId, locationId)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for i := len(l) - 1; i >= 0; i-- {
+		if !l[i].After(t) {
+			return s.Load(clientId, locationId, l[i])
+		}
+	}
+	return Snapshot{}, ErrNotFound
+}
+func (s *Store) Latest(clientId string, locationId string) (Snapshot, error) {
+	return s.At(clientId, locationId, time.Now())
+}
+
+/*
+ * Take fetches the device list for q's ClientId/LocationId and compares
+ * it with the latest snapshot. A new snapshot is saved when anything
+ * changed, or when there was none; the report's To is then its time.
+ */
+func (s *Store) Take(q secureWorks.Query) (Report, error) {
+	r := Report{ClientId: q.ClientId, LocationId: q.LocationId}
+	x, err := secureWorks.GetDeviceList(q)
+	if err != nil {
+		return r, err
+	}
+	prev, err := s.Latest(q.ClientId, q.LocationId)
+	if err != nil && err != ErrNotFound {
+		return r, err
+	}
+	r.From = prev.Taken
+	r.Changes = secureWorks.DiffDevices(prev.Devices, x.Devices)
+	if err == nil && len(r.Changes) == 0 {
+		r.To = prev.Taken
+		return r, nil
+	}
+	n, err := s.Save(q.ClientId, q.LocationId, x.Devices)
+	r.To = n.Taken
+	return r, err
+}
+
+/* Diff reports the changes between two snapshots */
+func Diff(from Snapshot, to Snapshot) Report {
+	return Report{ClientId: to.ClientId, LocationId: to.LocationId, From: from.Taken, To: to.Taken,
+		Changes: secureWorks.DiffDevices(from.Devices, to.Devices)}
+}
+
+/* WriteCSV writes one row per change, with the scope and snapshot times on each */
+func (r Report) WriteCSV(w io.Writer, header bool) error {
+	c := csv.NewWriter(w)
+	if header {
+		c.Write([]string{"ClientId", "LocationId", "From", "To", "Change", "DeviceId", "DeviceName", "Old", "New"})
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	for _, ch := range r.Changes {
+		c.Write([]string{r.ClientId, r.LocationId, date(r.From), date(r.To), ch.Change,
+			strconv.Itoa(ch.DeviceId), ch.DeviceName, ch.From, ch.To})
+	}
+	c.Flush()
+	return c.Error()
+}
@@ -0,0 +1,91 @@
+package snapshot
+
+import "bytes"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "secureWorks"
+import "testing"
+import "time"
+
+func TestStore(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Latest("7", ""); err != ErrNotFound {
+		t.Errorf("Latest of an empty store: %v", err)
+	}
+	devices := []secureWorks.DeviceList{{DeviceId: 2, DeviceName: "fw2"}, {DeviceId: 1, DeviceName: "fw1"}}
+	n, err := s.Save("7", "", devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Devices[0].DeviceId != 1 {
+		t.Errorf("devices not ordered by id: %+v", n.Devices)
+	}
+	/* scopes are kept apart */
+	if _, err := s.Save("8", "a/b", devices[:1]); err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.Times("7", "")
+	if err != nil || len(l) != 1 || !l[0].Equal(n.Taken) {
+		t.Errorf("Times = %v, %v", l, err)
+	}
+	got, err := s.Latest("7", "")
+	if err != nil || len(got.Devices) != 2 || got.ClientId != "7" {
+		t.Errorf("Latest = %+v, %v", got, err)
+	}
+	if _, err := s.At("7", "", n.Taken.Add(-time.Second)); err != ErrNotFound {
+		t.Errorf("At before the first snapshot: %v", err)
+	}
+	if got, err := s.Latest("8", "a/b"); err != nil || len(got.Devices) != 1 {
+		t.Errorf("Latest of the second scope = %+v, %v", got, err)
+	}
+}
+func TestDiffWriteCSV(t *testing.T) {
+	from := Snapshot{ClientId: "7", Taken: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Devices: []secureWorks.DeviceList{{DeviceId: 1, DeviceName: "fw1", DeviceIp: "10.0.0.1"}}}
+	to := Snapshot{ClientId: "7", Taken: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Devices: []secureWorks.DeviceList{{DeviceId: 1, DeviceName: "fw1", DeviceIp: "10.0.0.9"}}}
+	var b bytes.Buffer
+	if err := Diff(from, to).WriteCSV(&b, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "ClientId,LocationId,From,To,Change,DeviceId,DeviceName,Old,New\n" +
+		"7,,2024-01-01T00:00:00Z,2024-01-02T00:00:00Z,reip,1,fw1,10.0.0.1,10.0.0.9\n"
+	if b.String() != want {
+		t.Errorf("WriteCSV\n got %q\nwant %q", b.String(), want)
+	}
+	b.Reset()
+	if Diff(to, to).WriteCSV(&b, false); b.Len() != 0 {
+		t.Errorf("no changes wrote %q", b.String())
+	}
+}
+func TestTake(t *testing.T) {
+	ip := "10.0.0.1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<Envelope><Body><getDeviceListResponse><device><deviceId>1</deviceId>`+
+			`<deviceName>fw1</deviceName><deviceIp>`+ip+`</deviceIp></device></getDeviceListResponse></Body></Envelope>`)
+	}))
+	defer srv.Close()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := secureWorks.Query{ApiUri: srv.URL, ClientId: "7"}
+
+	r, err := s.Take(q)
+	if err != nil || len(r.Changes) != 1 || r.Changes[0].Change != secureWorks.DeviceAdded || r.To.IsZero() {
+		t.Fatalf("first Take = %+v, %v", r, err)
+	}
+	/* unchanged: reported against the existing snapshot, nothing saved */
+	r, err = s.Take(q)
+	if l, _ := s.Times("7", ""); err != nil || len(r.Changes) != 0 || !r.To.Equal(r.From) || len(l) != 1 {
+		t.Errorf("unchanged Take = %+v, %v, %d snapshots", r, err, len(l))
+	}
+	ip = "10.0.0.2"
+	if r, err = s.Take(q); err != nil || len(r.Changes) != 1 || r.Changes[0].Change != secureWorks.DeviceReIp {
+		t.Errorf("changed Take = %+v, %v", r, err)
+	}
+}
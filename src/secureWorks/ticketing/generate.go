@@ -0,0 +1,8 @@
+/*
+ * Package ticketing holds the bindings generated from the TicketingService
+ * WSDL. The WSDL is only published to authenticated clients, so it is not
+ * vendored: save it here as TicketingService.wsdl and run go generate.
+ */
+package ticketing
+
+//go:generate go run ../../wsdlgen.go -wsdl TicketingService.wsdl -pkg ticketing -o ticketing.go
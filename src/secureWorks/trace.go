@@ -0,0 +1,282 @@
+package secureWorks
+
+import "bytes"
+import "context"
+import "crypto/rand"
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "sync"
+import "time"
+
+/*
+ * Tracer starts a span around each SOAP operation. The span started by
+ * makeSOAPrequest is a child of any span the Tracer finds in the Query's
+ * context (see WithContext), and its W3C traceparent is sent on the
+ * outgoing HTTP request.
+ */
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+type Span interface {
+	SetAttribute(key string, value string)
+	TraceParent() string
+	End(err error)
+}
+
+/* SpanData is a finished span as handed to a SpanExporter */
+type SpanData struct {
+	TraceId      string            `json:"traceId"`
+	SpanId       string            `json:"spanId"`
+	ParentSpanId string            `json:"parentSpanId,omitempty"`
+	Name         string            `json:"name"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+type SpanExporter interface {
+	ExportSpan(s SpanData)
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, string) {}
+func (nopSpan) TraceParent() string         { return "" }
+func (nopSpan) End(error)                   {}
+
+/* WithContext returns a copy of q whose requests carry ctx */
+func (q Query) WithContext(ctx context.Context) Query {
+	q.ctx = ctx
+	return q
+}
+func (q Query) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+func (q Query) startSpan(name string) (context.Context, Span) {
+	if q.Tracer == nil {
+		return q.context(), nopSpan{}
+	}
+	return q.Tracer.Start(q.context(), name)
+}
+
+/*
+ * NewTracer returns a Tracer handing every finished span to e. It is not
+ * OpenTelemetry compatible: only its own spans are found in a context, so
+ * an OTel span there is ignored and a new trace is started.
+ */
+func NewTracer(e SpanExporter) Tracer {
+	return &tracer{exporter: e}
+}
+
+type tracer struct {
+	exporter SpanExporter
+}
+type spanKey struct{}
+type span struct {
+	data     SpanData
+	mu       sync.Mutex
+	exporter SpanExporter
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &span{exporter: t.exporter}
+	s.data.Name = name
+	s.data.Start = time.Now()
+	s.data.SpanId = randomHex(8)
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.data.TraceId = parent.data.TraceId
+		s.data.ParentSpanId = parent.data.SpanId
+	} else {
+		s.data.TraceId = randomHex(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+func (s *span) SetAttribute(key string, value string) {
+	s.mu.Lock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]string{}
+	}
+	s.data.Attributes[key] = value
+	s.mu.Unlock()
+}
+func (s *span) TraceParent() string {
+	return "00-" + s.data.TraceId + "-" + s.data.SpanId + "-01"
+}
+func (s *span) End(err error) {
+	s.mu.Lock()
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	d := s.data
+	s.mu.Unlock()
+	s.exporter.ExportSpan(d)
+}
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/* NewStdoutExporter writes each finished span to w as one JSON line */
+func NewStdoutExporter(w io.Writer) SpanExporter {
+	return &stdoutExporter{w: w}
+}
+
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *stdoutExporter) ExportSpan(s SpanData) {
+	e.mu.Lock()
+	json.NewEncoder(e.w).Encode(s)
+	e.mu.Unlock()
+}
+
+/*
+ * OTLPExporter batches spans and posts them as OTLP/HTTP JSON to a
+ * collector, e.g. http://localhost:4318/v1/traces. Batches go out every
+ * few seconds or when full; call Shutdown before exiting. Only the wire
+ * format is OTLP: this is not the OpenTelemetry SDK (no sampling,
+ * resource detection, retries or protobuf). A batch the collector does
+ * not accept is dropped; Flush and Shutdown return the error, and Errors
+ * (if set) gets those of the background flushes.
+ */
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	Errors      func(error)
+	mu          sync.Mutex
+	pending     []SpanData
+	once        sync.Once
+	stop        chan struct{}
+	closed      bool
+}
+
+func NewOTLPExporter(endpoint string, serviceName string) *OTLPExporter {
+	return &OTLPExporter{Endpoint: endpoint, ServiceName: serviceName}
+}
+func (e *OTLPExporter) ExportSpan(s SpanData) {
+	e.once.Do(func() {
+		e.stop = make(chan struct{})
+		go func() {
+			t := time.NewTicker(5 * time.Second)
+			defer t.Stop()
+			for {
+				select {
+				case <-e.stop:
+					return
+				case <-t.C:
+					e.report(e.Flush())
+				}
+			}
+		}()
+	})
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.pending = append(e.pending, s)
+	full := len(e.pending) >= 64
+	e.mu.Unlock()
+	if full {
+		go func() { e.report(e.Flush()) }()
+	}
+}
+func (e *OTLPExporter) report(err error) {
+	if err != nil && e.Errors != nil {
+		e.Errors(err)
+	}
+}
+
+/* Shutdown stops the batching timer and sends the pending spans; later spans are dropped */
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {})
+	e.mu.Lock()
+	if !e.closed && e.stop != nil {
+		close(e.stop)
+	}
+	e.closed = true
+	e.mu.Unlock()
+	return e.flush(ctx)
+}
+func (e *OTLPExporter) Flush() error {
+	return e.flush(context.Background())
+}
+func (e *OTLPExporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	type kv struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	attrs := func(m map[string]string) []kv {
+		var r []kv
+		for k, v := range m {
+			r = append(r, kv{k, map[string]string{"stringValue": v}})
+		}
+		return r
+	}
+	var spans []map[string]interface{}
+	for _, s := range batch {
+		o := map[string]interface{}{
+			"traceId":           s.TraceId,
+			"spanId":            s.SpanId,
+			"name":              s.Name,
+			"kind":              3,
+			"startTimeUnixNano": s.Start.UnixNano(),
+			"endTimeUnixNano":   s.End.UnixNano(),
+			"attributes":        attrs(s.Attributes),
+		}
+		if len(s.ParentSpanId) > 0 {
+			o["parentSpanId"] = s.ParentSpanId
+		}
+		if len(s.Error) > 0 {
+			o["status"] = map[string]interface{}{"code": 2, "message": s.Error}
+		}
+		spans = append(spans, o)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attrs(map[string]string{"service.name": e.ServiceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "secureWorks"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s: %d spans dropped: %s %s", e.Endpoint, len(batch), resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
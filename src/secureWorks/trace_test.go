@@ -0,0 +1,44 @@
+package secureWorks
+
+import "context"
+import "encoding/json"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "strings"
+import "testing"
+import "time"
+
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]interface{}
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.WriteHeader(status)
+		io.WriteString(w, "bad span\n")
+	}))
+	defer s.Close()
+	e := NewOTLPExporter(s.URL, "test")
+	start := time.Unix(1700000000, 0)
+	e.ExportSpan(SpanData{TraceId: "t1", SpanId: "s1", Name: "getUpdates", Start: start, End: start.Add(time.Second),
+		Attributes: map[string]string{"soap.operation": "getUpdates"}, Error: "fault"})
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	span := got["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	if span["name"] != "getUpdates" || span["startTimeUnixNano"].(float64) != 1.7e18 || span["status"].(map[string]interface{})["code"].(float64) != 2 {
+		t.Errorf("span = %v", span)
+	}
+
+	/* the collector's refusal is returned, not swallowed */
+	status = http.StatusBadRequest
+	e.ExportSpan(SpanData{TraceId: "t1", SpanId: "s2", Name: "getTicketDetail"})
+	err := e.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request") || !strings.Contains(err.Error(), "bad span") {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Errorf("Flush with nothing pending: %v", err)
+	}
+}
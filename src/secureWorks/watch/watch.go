@@ -0,0 +1,203 @@
+package watch
+
+import "context"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "math/rand"
+import "os"
+import "secureWorks"
+import "strconv"
+import "strings"
+import "time"
+
+/*
+ * Cursor remembers, per ticket, the newest worklog already reported, so
+ * each poll of GetUpdates only yields what is new. It is saved to a JSON
+ * file after every poll; a restarted watch picks up where it stopped.
+ */
+type Cursor struct {
+	Primed  bool                   `json:"primed"`
+	Tickets map[string]ticketState `json:"tickets"`
+	file    string
+}
+type ticketState struct {
+	Version     string `json:"version"`
+	LastWorkLog int64  `json:"lastWorkLog"`
+}
+
+/* Event is a newly seen ticket, or a new worklog entry on a known one */
+type Event struct {
+	Ticket  secureWorks.Ticket
+	WorkLog *secureWorks.WorkLog
+}
+
+func (e Event) IsNewTicket() bool {
+	return e.WorkLog == nil
+}
+
+type Options struct {
+	TicketTypes []string
+	/* Empty matches everything; values compare case-insensitively */
+	Severities []string
+	Clients    []string
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Limit      int
+	/* Report everything on the first poll instead of just priming the cursor */
+	ReplayFirst bool
+}
+
+func LoadCursor(fileName string) (*Cursor, error) {
+	c := &Cursor{Tickets: map[string]ticketState{}, file: fileName}
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if c.Tickets == nil {
+		c.Tickets = map[string]ticketState{}
+	}
+	return c, nil
+}
+func (c *Cursor) Save() error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+/* Update records tickets in the cursor and returns what it had not seen */
+func (c *Cursor) Update(tickets []secureWorks.Ticket) []Event {
+	var events []Event
+	for _, t := range tickets {
+		st, known := c.Tickets[t.TicketId]
+		if !known {
+			events = append(events, Event{Ticket: t})
+		}
+		last := st.LastWorkLog
+		for i := range t.WorkLogs {
+			w := t.WorkLogs[i]
+			if w.DateCreated > st.LastWorkLog {
+				if known {
+					events = append(events, Event{Ticket: t, WorkLog: &w})
+				}
+				if w.DateCreated > last {
+					last = w.DateCreated
+				}
+			}
+		}
+		c.Tickets[t.TicketId] = ticketState{Version: t.TicketVersion, LastWorkLog: last}
+	}
+	return events
+}
+func (o Options) match(t secureWorks.Ticket) bool {
+	in := func(v string, l []string) bool {
+		if len(l) == 0 {
+			return true
+		}
+		for _, x := range l {
+			if strings.EqualFold(strings.TrimSpace(x), v) {
+				return true
+			}
+		}
+		return false
+	}
+	return in(t.Severity, o.Severities) &&
+		(in(t.Client.Name, o.Clients) || in(strconv.Itoa(t.Client.Id), o.Clients))
+}
+
+/*
+ * poll fetches GetUpdates for each ticket type. A type that fails does
+ * not discard the others: their tickets come back along with the error.
+ */
+func poll(q secureWorks.Query, o Options) ([]secureWorks.Ticket, error) {
+	var tickets []secureWorks.Ticket
+	var errs []error
+	for _, tt := range o.TicketTypes {
+		tt = strings.TrimSpace(tt)
+		u, err := secureWorks.GetUpdates(q, tt, "ALL", o.Limit, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("GetUpdates %s: %w", tt, err))
+			continue
+		}
+		tickets = append(tickets, u.Tickets...)
+	}
+	return tickets, errors.Join(errs...)
+}
+
+/*
+ * Watch polls GetUpdates every Interval (±20% jitter) until ctx is done,
+ * calling fn for each new ticket and worklog that passes the filters.
+ * Failed polls back off exponentially, with jitter, up to MaxBackoff;
+ * the ticket types that did answer are still reported. The cursor is
+ * only primed by a poll where every type answered. Errors from saving
+ * the cursor end the watch.
+ */
+func Watch(ctx context.Context, q secureWorks.Query, c *Cursor, o Options, fn func(Event)) error {
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Minute
+	}
+	if o.Limit <= 0 {
+		o.Limit = 500
+	}
+	if len(o.TicketTypes) == 0 {
+		o.TicketTypes = []string{"INCIDENT"}
+	}
+	q = q.WithContext(ctx)
+	log := q.Logger
+
+	backoff := o.Interval
+	for {
+		tickets, err := poll(q, o)
+		wait := o.Interval
+		if err != nil {
+			if log != nil {
+				log.Warn("watch poll failed", "error", err, "retry_in", backoff)
+			}
+			wait = backoff
+			backoff *= 2
+			if backoff > o.MaxBackoff {
+				backoff = o.MaxBackoff
+			}
+		} else {
+			backoff = o.Interval
+		}
+		if len(tickets) > 0 || err == nil {
+			events := c.Update(tickets)
+			if c.Primed || o.ReplayFirst {
+				for _, e := range events {
+					if o.match(e.Ticket) {
+						fn(e)
+					}
+				}
+			}
+			if err == nil {
+				c.Primed = true
+			}
+			if err := c.Save(); err != nil {
+				return err
+			}
+		}
+
+		wait += time.Duration((rand.Float64()*0.4 - 0.2) * float64(wait))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
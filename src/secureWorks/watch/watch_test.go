@@ -0,0 +1,153 @@
+package watch
+
+import "context"
+import "fmt"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "path/filepath"
+import "regexp"
+import "secureWorks"
+import "strings"
+import "sync"
+import "testing"
+import "time"
+
+func TestCursorUpdate(t *testing.T) {
+	c, err := LoadCursor(filepath.Join(t.TempDir(), "cursor.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := func(at int64) secureWorks.WorkLog {
+		return secureWorks.WorkLog{DateCreated: at, Description: fmt.Sprint(at)}
+	}
+	kinds := func(l []Event) string {
+		var s []string
+		for _, e := range l {
+			if e.IsNewTicket() {
+				s = append(s, e.Ticket.TicketId)
+			} else {
+				s = append(s, e.Ticket.TicketId+"/"+e.WorkLog.Description)
+			}
+		}
+		return strings.Join(s, ",")
+	}
+
+	/* a new ticket is one event, its existing worklogs are not reported */
+	ev := c.Update([]secureWorks.Ticket{{TicketId: "INC-1", WorkLogs: []secureWorks.WorkLog{w(10), w(20)}}})
+	if got := kinds(ev); got != "INC-1" {
+		t.Errorf("first Update = %s", got)
+	}
+	ev = c.Update([]secureWorks.Ticket{
+		{TicketId: "INC-1", WorkLogs: []secureWorks.WorkLog{w(10), w(20), w(40), w(30)}},
+		{TicketId: "INC-2"},
+	})
+	if got := kinds(ev); got != "INC-1/40,INC-1/30,INC-2" {
+		t.Errorf("second Update = %s", got)
+	}
+	if ev := c.Update([]secureWorks.Ticket{{TicketId: "INC-1", WorkLogs: []secureWorks.WorkLog{w(40)}}}); len(ev) != 0 {
+		t.Errorf("nothing new, got %s", kinds(ev))
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadCursor(c.file)
+	if err != nil || d.Tickets["INC-1"].LastWorkLog != 40 || len(d.Tickets) != 2 {
+		t.Errorf("LoadCursor = %+v, %v", d, err)
+	}
+}
+func TestMatch(t *testing.T) {
+	tk := secureWorks.Ticket{Severity: "High", Client: secureWorks.IdName{Id: 7, Name: "Acme"}}
+	for _, c := range []struct {
+		o    Options
+		want bool
+	}{
+		{Options{}, true},
+		{Options{Severities: []string{"critical", " HIGH "}}, true},
+		{Options{Severities: []string{"low"}}, false},
+		{Options{Clients: []string{"acme"}}, true},
+		{Options{Clients: []string{"7"}}, true},
+		{Options{Clients: []string{"Beta", "8"}}, false},
+		{Options{Severities: []string{"high"}, Clients: []string{"beta"}}, false},
+	} {
+		if got := c.o.match(tk); got != c.want {
+			t.Errorf("match(%+v) = %v", c.o, got)
+		}
+	}
+}
+
+/*
+ * updatesServer answers getUpdates with the tickets of the requested type
+ * from updates(), or a fault for types it has none for.
+ */
+func updatesServer(t *testing.T, updates func(ticketType string) (string, bool)) *httptest.Server {
+	typeRe := regexp.MustCompile(`<ticketType>([^<]*)</ticketType>`)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		m := typeRe.FindSubmatch(b)
+		tickets, ok := updates(string(m[1]))
+		if !ok {
+			io.WriteString(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Server</faultcode><faultstring>down</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+		io.WriteString(w, `<Envelope><Body><getUpdatesResponse>`+tickets+`</getUpdatesResponse></Body></Envelope>`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+func TestPollPartial(t *testing.T) {
+	s := updatesServer(t, func(tt string) (string, bool) {
+		return `<ticket><ticketId>` + tt + `-1</ticketId></ticket>`, tt != "CHANGE"
+	})
+	q := secureWorks.Query{ApiUri: s.URL}
+	tickets, err := poll(q, Options{TicketTypes: []string{"INCIDENT", " CHANGE", "SERVICE_REQUEST "}, Limit: 10})
+	if err == nil || !strings.Contains(err.Error(), "GetUpdates CHANGE") {
+		t.Errorf("poll error %v", err)
+	}
+	if len(tickets) != 2 || tickets[0].TicketId != "INCIDENT-1" || tickets[1].TicketId != "SERVICE_REQUEST-1" {
+		t.Errorf("poll tickets %+v", tickets)
+	}
+}
+func TestWatch(t *testing.T) {
+	var mu sync.Mutex
+	polls := 0
+	s := updatesServer(t, func(tt string) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tt == "CHANGE" {
+			/* CHANGE fails once, so the first poll is partial */
+			polls++
+			return "", polls > 1
+		}
+		w := `<worklogs><dateCreated>10</dateCreated><description>opened</description></worklogs>`
+		if polls > 2 {
+			w += `<worklogs><dateCreated>20</dateCreated><description>escalated</description></worklogs>`
+		}
+		return `<ticket><ticketId>INC-1</ticketId><severity>HIGH</severity>` + w + `</ticket>`, true
+	})
+	c, err := LoadCursor(filepath.Join(t.TempDir(), "cursor.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []string
+	o := Options{TicketTypes: []string{"INCIDENT", "CHANGE"}, Interval: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	err = Watch(ctx, secureWorks.Query{ApiUri: s.URL}, c, o, func(e Event) {
+		if e.IsNewTicket() {
+			got = append(got, "new "+e.Ticket.TicketId)
+		} else {
+			got = append(got, "worklog "+e.WorkLog.Description)
+		}
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("Watch = %v", err)
+	}
+	/* INC-1 was seen while priming, so only the later worklog is reported */
+	if strings.Join(got, ",") != "worklog escalated" || !c.Primed {
+		t.Errorf("events %q, primed %v", got, c.Primed)
+	}
+}
@@ -0,0 +1,338 @@
+package wsdl
+
+import "bytes"
+import "encoding/xml"
+import "fmt"
+import "go/format"
+import "os"
+import "strings"
+import "unicode"
+
+/*
+ * Generator for typed bindings from a document/literal WSDL 1.1 such as
+ * the TicketingService one. For every operation of every portType it
+ * emits a request struct (the input element's children, minus the
+ * userName/password credentials, which secureWorks.Call adds), a
+ * response struct named after the output element, and a client function
+ * calling secureWorks.Call. Named and inline complex types become
+ * structs; XSD simple types map to Go basic types (dates stay strings).
+ */
+type Definitions struct {
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Schemas         []Schema   `xml:"types>schema"`
+	Messages        []Message  `xml:"message"`
+	PortTypes       []PortType `xml:"portType"`
+}
+type Schema struct {
+	Elements     []Element     `xml:"element"`
+	ComplexTypes []ComplexType `xml:"complexType"`
+	SimpleTypes  []SimpleType  `xml:"simpleType"`
+}
+type Element struct {
+	Name        string       `xml:"name,attr"`
+	Type        string       `xml:"type,attr"`
+	Ref         string       `xml:"ref,attr"`
+	MaxOccurs   string       `xml:"maxOccurs,attr"`
+	ComplexType *ComplexType `xml:"complexType"`
+}
+type ComplexType struct {
+	Name       string      `xml:"name,attr"`
+	Sequence   []Element   `xml:"sequence>element"`
+	All        []Element   `xml:"all>element"`
+	Attributes []Attribute `xml:"attribute"`
+	Extension  *Extension  `xml:"complexContent>extension"`
+}
+type Extension struct {
+	Base       string      `xml:"base,attr"`
+	Sequence   []Element   `xml:"sequence>element"`
+	Attributes []Attribute `xml:"attribute"`
+}
+type Attribute struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+type SimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction struct {
+		Base string `xml:"base,attr"`
+	} `xml:"restriction"`
+}
+type Message struct {
+	Name  string `xml:"name,attr"`
+	Parts []Part `xml:"part"`
+}
+type Part struct {
+	Name    string `xml:"name,attr"`
+	Element string `xml:"element,attr"`
+}
+type PortType struct {
+	Name       string      `xml:"name,attr"`
+	Operations []Operation `xml:"operation"`
+}
+type Operation struct {
+	Name   string `xml:"name,attr"`
+	Input  IO     `xml:"input"`
+	Output IO     `xml:"output"`
+}
+type IO struct {
+	Message string `xml:"message,attr"`
+}
+
+func Parse(b []byte) (*Definitions, error) {
+	d := &Definitions{}
+	if err := xml.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+func ParseFile(fileName string) (*Definitions, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return d, nil
+}
+
+/* XSD builtin types, by local name */
+var builtins = map[string]string{
+	"string": "string", "normalizedString": "string", "token": "string", "anyURI": "string",
+	"dateTime": "string", "date": "string", "time": "string", "duration": "string", "QName": "string",
+	"base64Binary": "string", "hexBinary": "string",
+	"boolean": "bool",
+	"int":     "int", "integer": "int", "short": "int", "byte": "int",
+	"nonNegativeInteger": "int", "positiveInteger": "int", "unsignedInt": "int", "unsignedShort": "int",
+	"long": "int64", "unsignedLong": "int64",
+	"float": "float64", "double": "float64", "decimal": "float64",
+}
+
+/* Credentials are added by secureWorks.Call, so request structs leave them out */
+var credentialElements = map[string]bool{"userName": true, "password": true}
+
+type generator struct {
+	complexTypes map[string]*ComplexType
+	simpleTypes  map[string]string
+	elements     map[string]*Element
+	messages     map[string]*Message
+	typeNames    map[string]string
+	used         map[string]bool
+	decls        bytes.Buffer
+	funcs        bytes.Buffer
+}
+
+func local(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+/* GoName exports an XML name: ticketDetail -> TicketDetail, ticket-id -> TicketId */
+func GoName(s string) string {
+	var b strings.Builder
+	up := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			up = true
+			continue
+		}
+		if up {
+			r = unicode.ToUpper(r)
+			up = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 || unicode.IsDigit([]rune(b.String())[0]) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+/* unique reserves a Go type name, suffixing a number on a clash */
+func (g *generator) unique(name string) string {
+	n := name
+	for i := 2; g.used[n]; i++ {
+		n = fmt.Sprintf("%s%d", name, i)
+	}
+	g.used[n] = true
+	return n
+}
+
+/*
+ * Generate returns gofmt'ed source of package pkg for d. source is named
+ * in the "Code generated" header.
+ */
+func Generate(d *Definitions, pkg string, source string) ([]byte, error) {
+	g := &generator{
+		complexTypes: map[string]*ComplexType{},
+		simpleTypes:  map[string]string{},
+		elements:     map[string]*Element{},
+		messages:     map[string]*Message{},
+		typeNames:    map[string]string{},
+		used:         map[string]bool{},
+	}
+	for si := range d.Schemas {
+		s := &d.Schemas[si]
+		for i := range s.ComplexTypes {
+			g.complexTypes[s.ComplexTypes[i].Name] = &s.ComplexTypes[i]
+		}
+		for _, t := range s.SimpleTypes {
+			g.simpleTypes[t.Name] = t.Restriction.Base
+		}
+		for i := range s.Elements {
+			g.elements[s.Elements[i].Name] = &s.Elements[i]
+		}
+	}
+	for i := range d.Messages {
+		g.messages[d.Messages[i].Name] = &d.Messages[i]
+	}
+
+	ops := 0
+	for _, pt := range d.PortTypes {
+		for _, op := range pt.Operations {
+			if err := g.operation(op); err != nil {
+				return nil, fmt.Errorf("operation %s: %v", op.Name, err)
+			}
+			ops++
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by wsdlgen from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	if ops > 0 {
+		b.WriteString("import \"encoding/xml\"\nimport \"secureWorks\"\n\n")
+	}
+	b.Write(g.decls.Bytes())
+	b.Write(g.funcs.Bytes())
+	return format.Source(b.Bytes())
+}
+
+/* element resolves a message's single part to its schema element */
+func (g *generator) element(message string) (*Element, error) {
+	m, ok := g.messages[local(message)]
+	if !ok {
+		return nil, fmt.Errorf("no message %q", message)
+	}
+	if len(m.Parts) != 1 || len(m.Parts[0].Element) == 0 {
+		return nil, fmt.Errorf("message %s is not document/literal with one element part", m.Name)
+	}
+	e, ok := g.elements[local(m.Parts[0].Element)]
+	if !ok {
+		return nil, fmt.Errorf("no element %q", m.Parts[0].Element)
+	}
+	return e, nil
+}
+
+/* elementType is the complex type of a top level element, inline or named */
+func (g *generator) elementType(e *Element) *ComplexType {
+	if e.ComplexType != nil {
+		return e.ComplexType
+	}
+	if t, ok := g.complexTypes[local(e.Type)]; ok {
+		return t
+	}
+	return &ComplexType{}
+}
+func (g *generator) operation(op Operation) error {
+	in, err := g.element(op.Input.Message)
+	if err != nil {
+		return err
+	}
+	out, err := g.element(op.Output.Message)
+	if err != nil {
+		return err
+	}
+	fn := GoName(op.Name)
+	req := g.unique(fn + "Request")
+	resp := GoName(out.Name)
+	if !strings.HasSuffix(resp, "Response") {
+		resp += "Response"
+	}
+	resp = g.unique(resp)
+	g.structDecl(req, "", g.elementType(in), true)
+	g.structDecl(resp, out.Name, g.elementType(out), false)
+	fmt.Fprintf(&g.funcs, "/* %s calls %s */\n", fn, op.Name)
+	fmt.Fprintf(&g.funcs, "func %s(q secureWorks.Query, r %s) (*%s, error) {\n", fn, req, resp)
+	fmt.Fprintf(&g.funcs, "\tx := new(%s)\n", resp)
+	fmt.Fprintf(&g.funcs, "\terr := secureWorks.Call(q, %q, r, x)\n\treturn x, err\n}\n", op.Name)
+	return nil
+}
+
+/* fields flattens a complex type's content, extension base first */
+func (g *generator) fields(t *ComplexType) ([]Element, []Attribute) {
+	elems := append(append([]Element{}, t.Sequence...), t.All...)
+	attrs := append([]Attribute{}, t.Attributes...)
+	if x := t.Extension; x != nil {
+		if base, ok := g.complexTypes[local(x.Base)]; ok {
+			be, ba := g.fields(base)
+			elems = append(be, elems...)
+			attrs = append(ba, attrs...)
+		}
+		elems = append(elems, x.Sequence...)
+		attrs = append(attrs, x.Attributes...)
+	}
+	return elems, attrs
+}
+func (g *generator) structDecl(name string, xmlName string, t *ComplexType, request bool) {
+	elems, attrs := g.fields(t)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	if len(xmlName) > 0 {
+		fmt.Fprintf(&b, "\tXMLName xml.Name `xml:\"%s\"`\n", xmlName)
+	}
+	for _, e := range elems {
+		if len(e.Ref) > 0 {
+			if r, ok := g.elements[local(e.Ref)]; ok {
+				max := e.MaxOccurs
+				e = *r
+				e.MaxOccurs = max
+			}
+		}
+		if request && credentialElements[e.Name] {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s %s `xml:\"%s\"`\n", GoName(e.Name), g.fieldType(name, e), e.Name)
+	}
+	for _, a := range attrs {
+		fmt.Fprintf(&b, "\t%s %s `xml:\"%s,attr\"`\n", GoName(a.Name), g.typeRef(a.Type), a.Name)
+	}
+	b.WriteString("}\n")
+	g.decls.Write(b.Bytes())
+}
+func (g *generator) fieldType(owner string, e Element) string {
+	var t string
+	if e.ComplexType != nil {
+		t = g.unique(owner + GoName(e.Name))
+		g.structDecl(t, "", e.ComplexType, false)
+	} else {
+		t = g.typeRef(e.Type)
+	}
+	if e.MaxOccurs == "unbounded" || (len(e.MaxOccurs) > 0 && e.MaxOccurs != "0" && e.MaxOccurs != "1") {
+		return "[]" + t
+	}
+	return t
+}
+func (g *generator) typeRef(qname string) string {
+	n := local(qname)
+	if t, ok := g.typeNames[n]; ok {
+		return t
+	}
+	/* Named complex types are declared on first use, so wrapper types of the operation elements are not */
+	if ct, ok := g.complexTypes[n]; ok {
+		t := g.unique(GoName(n))
+		g.typeNames[n] = t
+		g.structDecl(t, "", ct, false)
+		return t
+	}
+	if base, ok := g.simpleTypes[n]; ok && local(base) != n {
+		return g.typeRef(base)
+	}
+	if t, ok := builtins[n]; ok {
+		return t
+	}
+	return "string"
+}
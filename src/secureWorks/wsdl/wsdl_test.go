@@ -0,0 +1,68 @@
+package wsdl
+
+import "go/parser"
+import "go/token"
+import "strings"
+import "testing"
+
+func TestGenerate(t *testing.T) {
+	d, err := ParseFile("testdata/sample.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Generate(d, "ticketing", "sample.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+	if _, err := parser.ParseFile(token.NewFileSet(), "ticketing.go", b, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"// Code generated by wsdlgen from sample.wsdl; DO NOT EDIT.",
+		"package ticketing",
+		/* credentials are left to secureWorks.Call */
+		"type GetQueueCountRequest struct {\n\tTicketType string `xml:\"ticketType\"`\n}",
+		"XMLName xml.Name `xml:\"getQueueCountResponse\"`",
+		"Return  int      `xml:\"return\"`",
+		/* extension base fields first, then the extension's, then attributes */
+		"type Ticket struct {\n\tTicketId           string           `xml:\"ticketId\"`\n\tDateCreated        int64            `xml:\"dateCreated\"`",
+		"Worklogs           []TicketWorklogs `xml:\"worklogs\"`",
+		"Version            int              `xml:\"version,attr\"`",
+		"IsPublic    bool   `xml:\"isPublic\"`",
+		"func GetTicketDetail(q secureWorks.Query, r GetTicketDetailRequest) (*GetTicketDetailResponse, error) {",
+		"secureWorks.Call(q, \"getTicketDetail\", r, x)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in\n%s", want, src)
+		}
+	}
+	/* wrapper types of the operation elements are not declared on their own */
+	for _, unwanted := range []string{"type GetQueueCount struct", "type BaseTicket struct", "UserName"} {
+		if strings.Contains(src, unwanted) {
+			t.Errorf("unexpected %q in\n%s", unwanted, src)
+		}
+	}
+}
+func TestGenerateErrors(t *testing.T) {
+	d, err := Parse([]byte(`<definitions><message name="in"><part name="a" type="xs:string"/></message>
+<portType name="p"><operation name="op"><input message="tns:in"/><output message="tns:out"/></operation></portType></definitions>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(d, "x", "x.wsdl"); err == nil || !strings.Contains(err.Error(), "operation op") {
+		t.Errorf("rpc style message: got %v", err)
+	}
+}
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"ticketDetail": "TicketDetail",
+		"ticket-id":    "TicketId",
+		"return":       "Return",
+		"3des":         "X3des",
+	} {
+		if got := GoName(in); got != want {
+			t.Errorf("GoName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package secureWorks
+
+import "bytes"
+import "crypto/rand"
+import "crypto/sha1"
+import "encoding/base64"
+import "encoding/xml"
+import "time"
+
+const (
+	wsseNS         = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsuNS          = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	passwordDigest = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+	base64Binary   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+)
+
+/*
+ * With WSSecurity set in the config the credentials travel once, as a
+ * UsernameToken in the SOAP header, and the password itself is replaced
+ * by Base64(SHA1(nonce + created + password)).
+ */
+func soapHeader(q Query) string {
+	if !q.WSSecurity {
+		return "<soapenv:Header/>"
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	created := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
+	digest := wsseDigest(nonce, created, q.Password)
+
+	return `<soapenv:Header>
+      <wsse:Security soapenv:mustUnderstand="1" xmlns:wsse="` + wsseNS + `" xmlns:wsu="` + wsuNS + `">
+         <wsse:UsernameToken>
+            <wsse:Username>` + xmlEscape(q.UserName) + `</wsse:Username>
+            <wsse:Password Type="` + passwordDigest + `">` + digest + `</wsse:Password>
+            <wsse:Nonce EncodingType="` + base64Binary + `">` + base64.StdEncoding.EncodeToString(nonce) + `</wsse:Nonce>
+            <wsu:Created>` + created + `</wsu:Created>
+         </wsse:UsernameToken>
+      </wsse:Security>
+   </soapenv:Header>`
+}
+
+/* wsseDigest is the UsernameToken PasswordDigest, Base64(SHA1(nonce + created + password)) */
+func wsseDigest(nonce []byte, created string, password string) string {
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+/* Operation body credentials, omitted when they are sent in the header */
+func credentials(q Query) string {
+	if q.WSSecurity {
+		return ""
+	}
+	return `
+         <userName>` + xmlEscape(q.UserName) + `</userName>
+         <password>` + xmlEscape(q.Password) + `</password>`
+}
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package secureWorks
+
+import "encoding/base64"
+import "regexp"
+import "strings"
+import "testing"
+
+func TestWSSEDigest(t *testing.T) {
+	/* the UsernameToken example used by WSS4J and soapUI */
+	nonce, _ := base64.StdEncoding.DecodeString("LKqI6G/AikKCQrN0zqZFlg==")
+	if got := wsseDigest(nonce, "2010-09-16T07:50:45Z", "userpassword"); got != "tuOSpGlFlIXsozq4HFNeeGeFLEI=" {
+		t.Errorf("wsseDigest = %q", got)
+	}
+}
+func TestSoapHeader(t *testing.T) {
+	q := Query{UserName: "soc&co", Password: "s3cret"}
+	if soapHeader(q) != "<soapenv:Header/>" || !strings.Contains(credentials(q), "<userName>soc&amp;co</userName>") {
+		t.Error("plain credentials not in the body")
+	}
+	q.WSSecurity = true
+	h := soapHeader(q)
+	if len(credentials(q)) > 0 || strings.Contains(h, "s3cret") {
+		t.Errorf("password sent with WSSecurity: %s", h)
+	}
+	/* the digest in the header verifies against its own nonce and created */
+	m := regexp.MustCompile(`(?s)<wsse:Password[^>]*>([^<]+)</wsse:Password>.*<wsse:Nonce[^>]*>([^<]+)</wsse:Nonce>.*<wsu:Created>([^<]+)</wsu:Created>`).FindStringSubmatch(h)
+	if m == nil {
+		t.Fatalf("UsernameToken not found in %s", h)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil || len(nonce) != 16 {
+		t.Fatalf("nonce %q: %v", m[2], err)
+	}
+	if want := wsseDigest(nonce, m[3], "s3cret"); m[1] != want {
+		t.Errorf("digest %s, want %s", m[1], want)
+	}
+	if !strings.Contains(h, "<wsse:Username>soc&amp;co</wsse:Username>") {
+		t.Errorf("username not escaped: %s", h)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/archive"
+import "flag"
+import "strings"
+import "time"
+
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	fileName := flag.String("c", "", "Config File <required>")
+	Profile := flag.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Dir := flag.String("d", "secureworks-archive", "Archive Directory")
+	TicketTypes := flag.String("t", "INCIDENT", "Ticket Types, comma separated")
+	Limit := flag.Int("l", 500, "Ticket Limit per poll (Max is 500)")
+	Interval := flag.Duration("i", 0, "Poll Interval <optional> (default: sync once and exit)")
+	Help := flag.Bool("h", false, "Help")
+	Debug := flag.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	flag.Parse()
+	if len(*fileName) == 0 || *Help == true {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Must specify Config file with -c\n")
+		os.Exit(0)
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	a, err := archive.Open(*Dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	o := archive.SyncOptions{Limit: *Limit}
+	for _, t := range strings.Split(*TicketTypes, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			o.TicketTypes = append(o.TicketTypes, t)
+		}
+	}
+	for {
+		r, err := archive.Sync(l, a, o)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		for _, t := range r.Added {
+			fmt.Printf("%s,%s\n", t.TicketId, t.TicketVersion)
+		}
+		for id, err := range r.Errors {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", id, err)
+		}
+		if *Interval == 0 {
+			if err != nil || len(r.Errors) > 0 {
+				os.Exit(1)
+			}
+			break
+		}
+		time.Sleep(*Interval)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "flag"
+import "context"
+import "os/signal"
+import "strings"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+	fmt.Fprintf(os.Stderr, "Usage: tenants list -c config           Customers (and locations) fanned out to\n")
+	fmt.Fprintf(os.Stderr, "       tenants devices -c config        GetDeviceList for every customer\n")
+	fmt.Fprintf(os.Stderr, "       tenants contacts -c config       GetContactList for every customer\n")
+	fmt.Fprintf(os.Stderr, "       tenants count -c config [-t]     GetQueueCount, once for the account\n")
+	fmt.Fprintf(os.Stderr, "       tenants queue -c config [-t -l]  GetQueueTicketIds, once for the account\n")
+	fmt.Fprintf(os.Stderr, "Rows are prefixed with ClientId,LocationId,Client. Failed customers are\n")
+	fmt.Fprintf(os.Stderr, "reported on stderr and the exit status is 2 when some (not all) failed.\n")
+	fmt.Fprintf(os.Stderr, "The queue calls take no client or location, so they are not fanned out.\n")
+	os.Exit(0)
+}
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "list", "devices", "contacts", "count", "queue":
+		tenants(os.Args[1], os.Args[2:])
+	default:
+		usage()
+	}
+}
+func split(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+func tenants(op string, args []string) {
+	fs := flag.NewFlagSet("tenants "+op, flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File <required>")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Clients := fs.String("clients", "", "Only these Clients (name or id), comma separated")
+	Locations := fs.String("locations", "", "LocationIds to query for each client, comma separated (default: those of its devices)")
+	Parallel := fs.Int("parallel", 4, "Customers queried at once")
+	TicketType := fs.String("t", "INCIDENT", "Ticket Type (count, queue)")
+	Limit := fs.Int("l", 25, "Ticket Limit (queue; Max is 500)")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if len(*fileName) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	l = l.WithContext(ctx)
+
+	/* the queue is the account's, whichever client is asked for */
+	switch op {
+	case "count":
+		x, err := secureWorks.GetQueueCount(l, *TicketType)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Count\n%d\n", x.Count)
+		return
+	case "queue":
+		x, err := secureWorks.GetQueueTicketIds(l, *TicketType, *Limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("TicketId\n")
+		for _, id := range x.TicketIds {
+			fmt.Printf("%s\n", id)
+		}
+		return
+	}
+
+	/* customers whose locations could not be listed are still queried, with the config's */
+	t, terr := secureWorks.Tenants(l, split(*Locations), split(*Clients), *Parallel)
+	if f, ok := terr.(*secureWorks.FanOutError); ok {
+		for _, e := range f.Failed {
+			fmt.Fprintf(os.Stderr, "WARN: could not list locations, using %q: %v\n", l.LocationId, e)
+		}
+	} else if terr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", terr)
+		os.Exit(1)
+	}
+	tag := func(t secureWorks.Tenant) string {
+		return t.ClientId + "," + t.LocationId + "," + t.Name
+	}
+	switch op {
+	case "list":
+		fmt.Printf("ClientId,LocationId,Client\n")
+		for _, v := range t {
+			fmt.Printf("%s\n", tag(v))
+		}
+	case "devices":
+		var r []secureWorks.TenantDevice
+		r, err = secureWorks.FanOutDevices(l, t, *Parallel)
+		fmt.Printf("ClientId,LocationId,Client,DeviceId,DeviceName,DeviceAlias,DeviceIp,Location\n")
+		for _, v := range r {
+			d := v.Device
+			fmt.Printf("%s,%d,%s,%s,%s,%s\n", tag(v.Tenant), d.DeviceId, d.DeviceName, d.DeviceAlias, d.DeviceIp, d.Location.Name)
+		}
+	case "contacts":
+		var r []secureWorks.TenantContact
+		r, err = secureWorks.FanOutContacts(l, t, *Parallel)
+		fmt.Printf("ClientId,LocationId,Client,Id,Name\n")
+		for _, v := range r {
+			fmt.Printf("%s,%d,%s\n", tag(v.Tenant), v.Contact.Id, v.Contact.Name)
+		}
+	}
+
+	if f, ok := err.(*secureWorks.FanOutError); ok {
+		for _, e := range f.Failed {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", e)
+		}
+		if len(f.Failed) < f.Total {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
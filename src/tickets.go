@@ -0,0 +1,574 @@
+package main
+
+import "fmt"
+import "os"
+import "secureWorks"
+import "secureWorks/archive"
+import "flag"
+import "context"
+import "os/signal"
+import "log/slog"
+import "secureWorks/watch"
+import "time"
+import "encoding/json"
+import "strings"
+import "secureWorks/ioc"
+import "secureWorks/intel"
+import "path/filepath"
+import "bufio"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+	fmt.Fprintf(os.Stderr, "Usage: tickets list [-d dir]                   List archived tickets\n")
+	fmt.Fprintf(os.Stderr, "       tickets show [-d dir] -t id [-v ver]    Show an archived ticket\n")
+	fmt.Fprintf(os.Stderr, "       tickets search [-d dir] query...        Search archived tickets\n")
+	fmt.Fprintf(os.Stderr, "       tickets diff [-d dir] -t id [-live]     Show changes between versions\n")
+	fmt.Fprintf(os.Stderr, "       tickets watch -c config [-i interval]   Tail new tickets and worklogs\n")
+	fmt.Fprintf(os.Stderr, "       tickets iocs [-d dir] [-t ids] [-f fmt] Extract indicators (csv, json, stix)\n")
+	fmt.Fprintf(os.Stderr, "       tickets export [-d dir] -f stix|misp    Export incidents for sharing\n")
+	fmt.Fprintf(os.Stderr, "       tickets get -c config [-t ids]          Fetch live details (ids from stdin without -t)\n")
+	os.Exit(0)
+}
+func main() {
+	secureWorks.PassphraseFunc = secureWorks.PromptPassphrase
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "list":
+		ticketsList(os.Args[2:])
+	case "show":
+		ticketsShow(os.Args[2:])
+	case "search":
+		ticketsSearch(os.Args[2:])
+	case "diff":
+		ticketsDiff(os.Args[2:])
+	case "watch":
+		ticketsWatch(os.Args[2:])
+	case "iocs":
+		ticketsIocs(os.Args[2:])
+	case "export":
+		ticketsExport(os.Args[2:])
+	case "get":
+		ticketsGet(os.Args[2:])
+	default:
+		usage()
+	}
+}
+func openArchive(dir string) *archive.Archive {
+	a, err := archive.Open(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return a
+}
+
+/* printTicket renders t in the same formats as getTicketDetail */
+func printTicket(t secureWorks.Ticket, Csv bool, Long bool, Short bool, Work bool) {
+	if Csv == true {
+		t.PrintCsv()
+	}
+	if Work == true {
+		t.PrintWorkLogs()
+	}
+	if Short == true {
+		t.PrintDetails()
+	}
+	if Long == true {
+		t.PrintDetails()
+		t.PrintWorkLogs()
+	}
+}
+func ticketsList(args []string) {
+	fs := flag.NewFlagSet("tickets list", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	fs.Parse(args)
+
+	l, err := openArchive(*Dir).List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("TicketId,TicketVersion,DateModified,Severity,Status,Client,SymptomDescription\n")
+	for _, t := range l {
+		fmt.Printf("%s,%s,%d,%s,%s,%s,%s\n", t.TicketId, t.TicketVersion, t.DateModified,
+			t.Severity, t.Status, t.Client.Name, t.SymptomDescription)
+	}
+}
+func ticketsShow(args []string) {
+	fs := flag.NewFlagSet("tickets show", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	TicketNumber := fs.String("t", "", "Ticket Number <required>")
+	Version := fs.String("v", "", "Ticket Version <optional> (default: latest)")
+	All := fs.Bool("a", false, "Show every archived version")
+	Csv := fs.Bool("C", false, "CSV Output")
+	Long := fs.Bool("L", false, "Long Output")
+	Short := fs.Bool("S", false, "Short Output (don't include work logs)")
+	Work := fs.Bool("W", false, "Show Work Logs Only")
+	fs.Parse(args)
+	if len(*TicketNumber) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+	if *Csv == false && *Work == false && *Short == false && *Long == false {
+		*Long = true
+	}
+
+	a := openArchive(*Dir)
+	var l []secureWorks.Ticket
+	var err error
+	switch {
+	case *All == true:
+		l, err = a.Versions(*TicketNumber)
+	case len(*Version) > 0:
+		var t secureWorks.Ticket
+		t, err = a.Version(*TicketNumber, *Version)
+		l = append(l, t)
+	default:
+		var t secureWorks.Ticket
+		t, err = a.Latest(*TicketNumber)
+		l = append(l, t)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *TicketNumber, err)
+		os.Exit(1)
+	}
+	for _, t := range l {
+		printTicket(t, *Csv, *Long, *Short, *Work)
+	}
+}
+func ticketsSearch(args []string) {
+	fs := flag.NewFlagSet("tickets search", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	Limit := fs.Int("l", 25, "Result Limit (0 for all)")
+	Reindex := fs.Bool("r", false, "Rebuild the search index first")
+	Csv := fs.Bool("C", false, "CSV Output")
+	Long := fs.Bool("L", false, "Long Output")
+	Short := fs.Bool("S", false, "Short Output (don't include work logs)")
+	Work := fs.Bool("W", false, "Show Work Logs Only")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: tickets search [flags] [--] query...\n")
+		fmt.Fprintf(os.Stderr, "  e.g. 10.0.0.5 \"lateral movement\" severity:HIGH device:fw01 created:2023-01-01..2023-12-31 -status:CLOSED\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(0)
+	}
+
+	a := openArchive(*Dir)
+	if *Reindex == true {
+		if err := a.Reindex(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	r, err := a.Search(strings.Join(fs.Args(), " "), *Limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	/* Persist an index built on first use of an older archive */
+	a.Flush()
+
+	if *Csv == false && *Work == false && *Short == false && *Long == false {
+		fmt.Printf("Score,TicketId,TicketVersion,DateModified,Severity,Status,Client,SymptomDescription\n")
+		for _, v := range r {
+			t := v.Ticket
+			fmt.Printf("%.2f,%s,%s,%d,%s,%s,%s,%s\n", v.Score, t.TicketId, t.TicketVersion, t.DateModified,
+				t.Severity, t.Status, t.Client.Name, t.SymptomDescription)
+		}
+		return
+	}
+	for _, v := range r {
+		printTicket(v.Ticket, *Csv, *Long, *Short, *Work)
+	}
+}
+
+/*
+ * Without -f/-v the last two archived versions are compared; -live
+ * compares the latest archived version with GetTicketDetail.
+ */
+func ticketsDiff(args []string) {
+	fs := flag.NewFlagSet("tickets diff", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	TicketNumber := fs.String("t", "", "Ticket Number <required>")
+	From := fs.String("f", "", "From Version <optional> (default: previous)")
+	To := fs.String("v", "", "To Version <optional> (default: latest)")
+	Live := fs.Bool("live", false, "Compare against the live ticket (needs -c)")
+	fileName := fs.String("c", "", "Config File (for -live)")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Json := fs.Bool("j", false, "JSON Output")
+	fs.Parse(args)
+	if len(*TicketNumber) == 0 || (*Live == true && len(*fileName) == 0) {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	a := openArchive(*Dir)
+	versions, err := a.Versions(*TicketNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *TicketNumber, err)
+		os.Exit(1)
+	}
+	pick := func(v string, def int) secureWorks.Ticket {
+		if len(v) == 0 {
+			if def < 0 {
+				def = 0
+			}
+			return versions[def]
+		}
+		t, err := a.Version(*TicketNumber, v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s version %s: %v\n", *TicketNumber, v, err)
+			os.Exit(1)
+		}
+		return t
+	}
+
+	var from, to secureWorks.Ticket
+	if *Live == true {
+		l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		d, err := secureWorks.GetTicketDetail(l, *TicketNumber)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		from = pick(*From, len(versions)-1)
+		to = d.Detail
+	} else {
+		from = pick(*From, len(versions)-2)
+		to = pick(*To, len(versions)-1)
+	}
+
+	d := secureWorks.DiffTickets(from, to)
+	if *Json == true {
+		b, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Printf("%s\n", b)
+	} else {
+		d.Print()
+	}
+}
+func ticketsWatch(args []string) {
+	fs := flag.NewFlagSet("tickets watch", flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File <required>")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	State := fs.String("s", "secureworks-watch.json", "Cursor File (remembers what was already shown)")
+	Interval := fs.Duration("i", time.Minute, "Poll Interval")
+	TicketTypes := fs.String("t", "INCIDENT", "Ticket Types, comma separated")
+	Severity := fs.String("severity", "", "Only these Severities, comma separated")
+	Client := fs.String("client", "", "Only these Clients (name or id), comma separated")
+	Replay := fs.Bool("replay", false, "Show current tickets on first run instead of only new ones")
+	Json := fs.Bool("j", false, "JSON Output (one event per line)")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if len(*fileName) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	/* Failed polls are retried, so report them without exiting */
+	l.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	c, err := watch.LoadCursor(*State)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *State, err)
+		os.Exit(1)
+	}
+	split := func(s string) []string {
+		if len(s) == 0 {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
+	o := watch.Options{
+		TicketTypes: split(*TicketTypes),
+		Severities:  split(*Severity),
+		Clients:     split(*Client),
+		Interval:    *Interval,
+		ReplayFirst: *Replay,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = watch.Watch(ctx, l, c, o, func(e watch.Event) {
+		t := e.Ticket
+		switch {
+		case *Json == true:
+			b, _ := json.Marshal(map[string]interface{}{"ticket": t, "workLog": e.WorkLog, "newTicket": e.IsNewTicket()})
+			fmt.Printf("%s\n", b)
+		case e.IsNewTicket():
+			fmt.Printf("%s NEW     %s [%s] %s: %s\n", secureWorks.TicketTime(t.DateCreated).Format(time.RFC3339),
+				t.TicketId, t.Severity, t.Client.Name, t.SymptomDescription)
+		default:
+			fmt.Printf("%s WORKLOG %s [%s] %s\n", secureWorks.TicketTime(e.WorkLog.DateCreated).Format(time.RFC3339),
+				t.TicketId, e.WorkLog.Type, e.WorkLog.Description)
+		}
+	})
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+/* archivedTickets returns the latest version of each id, or every ticket when ids is empty */
+func archivedTickets(a *archive.Archive, ids string) []secureWorks.Ticket {
+	var tickets []secureWorks.Ticket
+	var err error
+	if len(ids) == 0 {
+		tickets, err = a.List()
+	} else {
+		for _, id := range strings.Split(ids, ",") {
+			var t secureWorks.Ticket
+			if t, err = a.Latest(strings.TrimSpace(id)); err != nil {
+				err = fmt.Errorf("%s: %v", id, err)
+				break
+			}
+			tickets = append(tickets, t)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return tickets
+}
+func ticketsIocs(args []string) {
+	fs := flag.NewFlagSet("tickets iocs", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	TicketNumbers := fs.String("t", "", "Ticket Numbers, comma separated <optional> (default: every archived ticket)")
+	Format := fs.String("f", "csv", "Output Format: csv, json or stix (STIX 2.1 bundle)")
+	Types := fs.String("type", "", "Only these indicator types, comma separated (ipv4,ipv6,domain,url,md5,sha1,sha256,cve)")
+	Defang := fs.Bool("defang", false, "Defang values in csv/json output (hxxp, [.])")
+	fs.Parse(args)
+
+	tickets := archivedTickets(openArchive(*Dir), *TicketNumbers)
+	want := map[string]bool{}
+	for _, t := range strings.Split(*Types, ",") {
+		if t = strings.TrimSpace(strings.ToLower(t)); len(t) > 0 {
+			want[t] = true
+		}
+	}
+
+	var all []ioc.Indicator
+	var objects []ioc.Object
+	seen := map[string]bool{}
+	for _, t := range tickets {
+		var l []ioc.Indicator
+		for _, i := range ioc.ExtractTicket(t) {
+			if len(want) == 0 || want[i.Type] {
+				l = append(l, i)
+			}
+		}
+		all = append(all, l...)
+		for _, o := range append(ioc.STIXObjects(l), ioc.Sightings(t, l)...) {
+			if id := o["id"].(string); !seen[id] {
+				seen[id] = true
+				objects = append(objects, o)
+			}
+		}
+	}
+	if *Defang == true && *Format != "stix" {
+		for n := range all {
+			all[n].Value = ioc.Defang(all[n].Type, all[n].Value)
+		}
+	}
+
+	switch *Format {
+	case "csv":
+		fmt.Printf("TicketId,Type,Value,Sources\n")
+		for _, i := range all {
+			fmt.Printf("%s,%s,%s,%s\n", i.TicketId, i.Type, i.Value, strings.Join(i.Sources, ";"))
+		}
+	case "json":
+		if all == nil {
+			all = []ioc.Indicator{}
+		}
+		b, _ := json.MarshalIndent(all, "", "  ")
+		fmt.Printf("%s\n", b)
+	case "stix":
+		b, _ := json.MarshalIndent(ioc.NewBundle(objects), "", "  ")
+		fmt.Printf("%s\n", b)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want csv, json or stix)\n", *Format)
+		os.Exit(1)
+	}
+}
+func ticketsExport(args []string) {
+	fs := flag.NewFlagSet("tickets export", flag.ExitOnError)
+	Dir := fs.String("d", "secureworks-archive", "Archive Directory")
+	TicketNumbers := fs.String("t", "", "Ticket Numbers, comma separated <optional> (default: every archived ticket)")
+	Format := fs.String("f", "stix", "Output Format: stix (STIX 2.1 bundle) or misp (MISP event JSON)")
+	Out := fs.String("o", "", "Output Directory, one <ticket>.stix.json/.misp.json each <optional> (default: stdout)")
+	Taxii := fs.String("taxii", "", "TAXII 2.1 Collection URL to add STIX objects to (password or token from $SECUREWORKS_TAXII_PASSWORD/$SECUREWORKS_TAXII_TOKEN)")
+	TaxiiUser := fs.String("taxii-user", "", "TAXII Username")
+	TlpFile := fs.String("tlp", "", "TLP per Client JSON File <optional> (default: amber)")
+	fileName := fs.String("c", "", "Config File <optional> (adds device context from GetDeviceList)")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if *Format != "stix" && *Format != "misp" || (len(*Taxii) > 0 && *Format != "stix") {
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "-f must be stix or misp; -taxii takes stix only\n")
+		os.Exit(0)
+	}
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var tlp *intel.TLP
+	if len(*TlpFile) > 0 {
+		var err error
+		if tlp, err = intel.LoadTLP(*TlpFile); err != nil {
+			fail(err)
+		}
+	}
+	var devices []secureWorks.DeviceList
+	if len(*fileName) > 0 {
+		l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if *Debug == true {
+			l.Debug = os.Stderr
+		}
+		if l.Inventory != nil {
+			devices, err = l.Inventory.Devices(l)
+		} else {
+			var x *secureWorks.DeviceListResponseEnvelope
+			if x, err = secureWorks.GetDeviceList(l); err == nil {
+				devices = x.Devices
+			}
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	var tx *intel.TAXII
+	if len(*Taxii) > 0 {
+		tx = &intel.TAXII{URL: *Taxii, Username: *TaxiiUser,
+			Password: os.Getenv("SECUREWORKS_TAXII_PASSWORD"), Token: os.Getenv("SECUREWORKS_TAXII_TOKEN")}
+	}
+
+	for _, t := range archivedTickets(openArchive(*Dir), *TicketNumbers) {
+		device := secureWorks.FindDevice(devices, t)
+		var v interface{}
+		if *Format == "stix" {
+			b := intel.STIX(t, device, tlp.Level(t))
+			if tx != nil {
+				if err := tx.Add(context.Background(), b.Objects); err != nil {
+					fail(fmt.Errorf("%s: %v", t.TicketId, err))
+				}
+				fmt.Printf("%s,%d objects\n", t.TicketId, len(b.Objects))
+				continue
+			}
+			v = b
+		} else {
+			v = intel.MISP(t, device, tlp.Level(t))
+		}
+		b, _ := json.MarshalIndent(v, "", "  ")
+		if len(*Out) == 0 {
+			fmt.Printf("%s\n", b)
+			continue
+		}
+		file := filepath.Join(*Out, strings.NewReplacer("/", "_", "\\", "_").Replace(t.TicketId)+"."+*Format+".json")
+		if err := os.MkdirAll(*Out, 0700); err != nil {
+			fail(err)
+		}
+		if err := os.WriteFile(file, append(b, '\n'), 0600); err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s\n", file)
+	}
+}
+
+/* ticketsGet fetches details concurrently; output keeps the order the ids were given in */
+func ticketsGet(args []string) {
+	fs := flag.NewFlagSet("tickets get", flag.ExitOnError)
+	fileName := fs.String("c", "", "Config File <required>")
+	Profile := fs.String("p", "", "Config Profile <optional> (default $SECUREWORKS_PROFILE)")
+	TicketNumbers := fs.String("t", "", "Ticket Numbers, comma separated (default: one per line on stdin)")
+	Workers := fs.Int("n", 4, "Tickets fetched at once (the config's RateLimit still applies)")
+	Csv := fs.Bool("C", false, "CSV Output")
+	Long := fs.Bool("L", false, "Long Output")
+	Short := fs.Bool("S", false, "Short Output (don't include work logs)")
+	Work := fs.Bool("W", false, "Show Work Logs Only")
+	Json := fs.Bool("j", false, "JSON Output (one ticket per line)")
+	Debug := fs.Bool("debug", false, "Dump redacted SOAP requests/responses to stderr")
+	fs.Parse(args)
+	if len(*fileName) == 0 {
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+	if *Csv == false && *Work == false && *Short == false && *Long == false && *Json == false {
+		*Long = true
+	}
+
+	var ids []string
+	if len(*TicketNumbers) > 0 && *TicketNumbers != "-" {
+		for _, id := range strings.Split(*TicketNumbers, ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				ids = append(ids, id)
+			}
+		}
+	} else {
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			if id := strings.TrimSpace(s.Text()); len(id) > 0 {
+				ids = append(ids, id)
+			}
+		}
+		if err := s.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	l, err := secureWorks.ReadConfigProfile(*fileName, *Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *Debug == true {
+		l.Debug = os.Stderr
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	failed := 0
+	for _, r := range secureWorks.GetTicketDetails(ctx, l, ids, *Workers) {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", r.TicketId, r.Err)
+			continue
+		}
+		if *Json == true {
+			b, _ := json.Marshal(r.Ticket)
+			fmt.Printf("%s\n", b)
+			continue
+		}
+		printTicket(r.Ticket, *Csv, *Long, *Short, *Work)
+	}
+	/* Exit 2 when some ids failed, 1 when all did */
+	if failed > 0 {
+		if failed < len(ids) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
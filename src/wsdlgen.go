@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+import "os"
+import "path/filepath"
+import "secureWorks/wsdl"
+import "flag"
+
+func main() {
+	File := flag.String("wsdl", "", "WSDL File")
+	Pkg := flag.String("pkg", "ticketing", "Go Package Name")
+	Output := flag.String("o", "", "Output File <optional> (default stdout)")
+	Help := flag.Bool("h", false, "Help")
+	flag.Parse()
+	if len(*File) == 0 || *Help == true {
+		fmt.Fprintf(os.Stderr, "Go Interface for SecureWorks Soap API by Jess Mahan\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Must specify WSDL file with -wsdl\n")
+		os.Exit(0)
+	}
+
+	d, err := wsdl.ParseFile(*File)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	b, err := wsdl.Generate(d, *Pkg, filepath.Base(*File))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(*Output) == 0 {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := os.WriteFile(*Output, b, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}